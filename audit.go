@@ -0,0 +1,166 @@
+package godmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Actions recorded in AuditEntries, besides the Identifiers of list actions.
+const (
+	AuditCreate = "create"
+	AuditChange = "change"
+	AuditDelete = "delete"
+)
+
+// FieldChange is the before and after value of a field changed by an admin user, as rendered by ValuesMapper.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// AuditEntry records a change made through the admin.
+type AuditEntry struct {
+	Time     time.Time     `json:"time"`
+	Username string        `json:"username"`
+	Model    string        `json:"model"`
+	PK       string        `json:"pk"`
+	Action   string        `json:"action"`
+	Changes  []FieldChange `json:"changes,omitempty"`
+}
+
+// AuditLog stores AuditEntries. The audit package provides in-memory, file and SQL AuditLogs.
+type AuditLog interface {
+	Record(ctx context.Context, entry AuditEntry) (err error)
+	// Entries returns the most recent entries first, limited to those of the model and pk if not "".
+	Entries(ctx context.Context, model string, pk string, limit int) (entries []AuditEntry, err error)
+}
+
+// the fields whose values differ between before and after, sorted by field name
+func diffValues(before, after map[string]string) []FieldChange {
+	var changes []FieldChange
+	for field, value := range after {
+		if old, exists := before[field]; !exists || old != value {
+			changes = append(changes, FieldChange{Field: field, Before: old, After: value})
+		}
+	}
+	for field, old := range before {
+		if _, exists := after[field]; !exists {
+			changes = append(changes, FieldChange{Field: field, Before: old})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// record an action in the audit log, if there is one, logging rather than failing the request on errors
+func (a *Admin) audit(ctx context.Context, modelAdmin ModelAdmin, pk string, action string, before, after map[string]string) {
+	if a.auditLog == nil {
+		return
+	}
+	entry := AuditEntry{
+		Time:     time.Now(),
+		Username: Username(ctx),
+		Model:    modelAdmin.ModelName,
+		PK:       pk,
+		Action:   action,
+		Changes:  diffValues(before, after),
+	}
+	if entry.Username == "" {
+		if accountId := AccountID(ctx); accountId != nil {
+			entry.Username = fmt.Sprint(accountId)
+		}
+	}
+	if err := a.auditLog.Record(ctx, entry); err != nil {
+		a.logger.Printf("error recording %v of %v %v: %v", action, modelAdmin.ModelName, pk, err)
+	}
+}
+
+// leave out changes to fields the user can't see
+func visibleChanges(modelAdmin ModelAdmin, entries []AuditEntry) []AuditEntry {
+	for i, entry := range entries {
+		var changes []FieldChange
+		for _, change := range entry.Changes {
+			if !modelAdmin.OmitFields[change.Field] {
+				changes = append(changes, change)
+			}
+		}
+		entries[i].Changes = changes
+	}
+	return entries
+}
+
+// the admin actions recorded for objects the user can view, for the home page
+func (a *Admin) recentActions(c *gin.Context) []AuditEntry {
+	if a.auditLog == nil {
+		return nil
+	}
+	entries, err := a.auditLog.Entries(requestContext(c), "", "", 4*recentActionCount)
+	if err != nil {
+		a.logger.Printf("error reading the audit log: %v", err)
+		return nil
+	}
+	admins := make(map[string]ModelAdmin)
+	for _, modelAdmin := range a.visibleAdmins(c) {
+		admins[modelAdmin.ModelName] = a.restrictFields(c, modelAdmin)
+	}
+	var recent []AuditEntry
+	for _, entry := range entries {
+		modelAdmin, exists := admins[entry.Model]
+		if !exists {
+			continue
+		}
+		if modelAdmin.ScopeFunc != nil {
+			if _, err := modelAdmin.get(modelAdmin.requestContext(c), entry.PK); err != nil {
+				continue
+			}
+		}
+		recent = append(recent, visibleChanges(modelAdmin, []AuditEntry{entry})...)
+		if len(recent) == recentActionCount {
+			break
+		}
+	}
+	return recent
+}
+
+const recentActionCount = 10
+
+// history of changes to an object
+func (a *Admin) history(c *gin.Context) {
+	modelAdmin, exists := a.lookupModelAdmin(c)
+	if !exists {
+		return
+	}
+	pk := c.Param("pk")
+	if !a.hasPermissions(c, modelAdmin.ModelName, PrivilegeView, []string{pk}) {
+		return
+	}
+	modelAdmin = a.restrictFields(c, modelAdmin)
+	ctx := modelAdmin.requestContext(c)
+	if _, err := modelAdmin.get(ctx, pk); err != nil {
+		// deleted objects still have a history, except for users who may only see some of the model's objects
+		if !errors.Is(err, ErrNotFound) || modelAdmin.ScopeFunc != nil {
+			a.renderError(c, err)
+			return
+		}
+	}
+	var entries []AuditEntry
+	if a.auditLog != nil {
+		var err error
+		if entries, err = a.auditLog.Entries(ctx, modelAdmin.ModelName, pk, 0); err != nil {
+			a.renderError(c, err)
+			return
+		}
+	}
+	dot := a.defaultDot(c)
+	dot["modelAdmin"] = modelAdmin
+	dot["pk"] = pk
+	dot["entries"] = visibleChanges(modelAdmin, entries)
+	c.HTML(http.StatusOK, "admin/history.html", dot)
+}
@@ -0,0 +1,143 @@
+// Package audit provides godmin.AuditLogs keeping the changes made through the admin
+// in memory, in a file or in an SQL database, and godmin.VersionStores keeping the
+// previous versions of objects in memory or in an SQL database.
+//
+//	log, err := audit.NewFile("/var/log/admin-audit.jsonl")
+//	admin.SetAuditLog(log)
+//	admin.SetVersionStore(audit.NewSQLVersions(db, "godmin_versions"))
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/gpitfield/godmin"
+)
+
+// matches reports whether the entry is one of the model and pk's, where "" matches any
+func matches(entry godmin.AuditEntry, model string, pk string) bool {
+	return (model == "" || entry.Model == model) && (pk == "" || entry.PK == pk)
+}
+
+// Memory is an AuditLog and VersionStore kept in memory, e.g. for tests or development.
+type Memory struct {
+	mu       sync.RWMutex
+	entries  []godmin.AuditEntry
+	max      int
+	versions map[[2]string][]godmin.Version // keyed by model and pk, oldest first
+}
+
+// NewMemory returns a Memory AuditLog keeping the latest max entries, or every entry if max is 0.
+// Every version is kept.
+func NewMemory(max int) *Memory {
+	return &Memory{max: max, versions: make(map[[2]string][]godmin.Version)}
+}
+
+func (m *Memory) Record(ctx context.Context, entry godmin.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, entry)
+	if m.max > 0 && len(m.entries) > m.max {
+		m.entries = m.entries[len(m.entries)-m.max:]
+	}
+	return nil
+}
+
+func (m *Memory) Entries(ctx context.Context, model string, pk string, limit int) ([]godmin.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return latest(m.entries, model, pk, limit), nil
+}
+
+func (m *Memory) AddVersion(ctx context.Context, version godmin.Version) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := [2]string{version.Model, version.PK}
+	version.Number = len(m.versions[key]) + 1
+	m.versions[key] = append(m.versions[key], version)
+	return nil
+}
+
+func (m *Memory) Versions(ctx context.Context, model string, pk string) ([]godmin.Version, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stored := m.versions[[2]string{model, pk}]
+	versions := make([]godmin.Version, len(stored))
+	for i, version := range stored {
+		versions[len(stored)-1-i] = version
+	}
+	return versions, nil
+}
+
+// the entries of the model and pk, latest first
+func latest(entries []godmin.AuditEntry, model string, pk string, limit int) []godmin.AuditEntry {
+	var out []godmin.AuditEntry
+	for i := len(entries) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		if matches(entries[i], model, pk) {
+			out = append(out, entries[i])
+		}
+	}
+	return out
+}
+
+// File is an AuditLog appending entries to a file as lines of JSON.
+// Entries reads the whole file, so it suits logs that are rotated regularly.
+type File struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// NewFile returns a File AuditLog appending to the file at path, creating it if needed.
+func NewFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &File{path: path, f: f}, nil
+}
+
+func (l *File) Record(ctx context.Context, entry godmin.AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.f.Write(append(line, '\n'))
+	return err
+}
+
+func (l *File) Entries(ctx context.Context, model string, pk string, limit int) ([]godmin.AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []godmin.AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry godmin.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		if matches(entry, model, pk) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return latest(entries, "", "", limit), nil
+}
+
+// Close closes the file.
+func (l *File) Close() error {
+	return l.f.Close()
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gpitfield/godmin"
+)
+
+func testAuditLog(t *testing.T, log godmin.AuditLog) {
+	ctx := context.Background()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, pk := range []string{"1", "2", "1"} {
+		entry := godmin.AuditEntry{Time: start.Add(time.Duration(i) * time.Minute), Username: "alice",
+			Model: "Post", PK: pk, Action: godmin.AuditChange,
+			Changes: []godmin.FieldChange{{Field: "Title", Before: "a", After: "b"}}}
+		if err := log.Record(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log.Record(ctx, godmin.AuditEntry{Time: start.Add(time.Hour), Model: "User", PK: "1", Action: godmin.AuditDelete})
+
+	entries, err := log.Entries(ctx, "Post", "1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || !entries[0].Time.Equal(start.Add(2*time.Minute)) || entries[1].Changes[0].After != "b" {
+		t.Errorf("got history %+v", entries)
+	}
+	entries, _ = log.Entries(ctx, "", "", 2)
+	if len(entries) != 2 || entries[0].Model != "User" || entries[1].PK != "1" {
+		t.Errorf("got recent entries %+v", entries)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testAuditLog(t, NewMemory(0))
+	log := NewMemory(2)
+	for _, pk := range []string{"a", "b", "c"} {
+		log.Record(context.Background(), godmin.AuditEntry{PK: pk})
+	}
+	if entries, _ := log.Entries(context.Background(), "", "", 0); len(entries) != 2 || entries[1].PK != "b" {
+		t.Errorf("got %+v", entries)
+	}
+}
+
+func TestFile(t *testing.T) {
+	log, err := NewFile(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	testAuditLog(t, log)
+}
+
+func testVersionStore(t *testing.T, store godmin.VersionStore) {
+	ctx := context.Background()
+	for _, title := range []string{"a", "b"} {
+		store.AddVersion(ctx, godmin.Version{Model: "Post", PK: "1", Values: map[string]string{"Title": title}})
+	}
+	store.AddVersion(ctx, godmin.Version{Model: "Post", PK: "2", Values: map[string]string{"Title": "c"}})
+	versions, err := store.Versions(ctx, "Post", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Number != 2 || versions[0].Values["Title"] != "b" || versions[1].Number != 1 {
+		t.Errorf("got versions %+v", versions)
+	}
+}
+
+func TestMemoryVersions(t *testing.T) {
+	testVersionStore(t, NewMemory(0))
+}
@@ -0,0 +1,169 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gpitfield/godmin"
+	"github.com/gpitfield/godmin/sqlparam"
+)
+
+// SQL is an AuditLog storing entries in a database table created along the lines of
+//
+//	CREATE TABLE godmin_audit (
+//		time     TIMESTAMP NOT NULL,
+//		username VARCHAR(255) NOT NULL,
+//		model    VARCHAR(255) NOT NULL,
+//		pk       VARCHAR(255) NOT NULL,
+//		action   VARCHAR(255) NOT NULL,
+//		changes  TEXT NOT NULL -- JSON
+//	);
+//	CREATE INDEX godmin_audit_object ON godmin_audit (model, pk, time);
+type SQL struct {
+	DB          *sql.DB
+	Table       string
+	Placeholder func(n int) string // the nth query parameter, starting from 1, see the sqlparam package
+}
+
+// NewSQL returns an SQL AuditLog storing entries in table, using "?" query parameters.
+func NewSQL(db *sql.DB, table string) *SQL {
+	return &SQL{DB: db, Table: table, Placeholder: sqlparam.Question}
+}
+
+func (s *SQL) Record(ctx context.Context, entry godmin.AuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+	placeholders := make([]string, 6)
+	for i := range placeholders {
+		placeholders[i] = s.Placeholder(i + 1)
+	}
+	_, err = s.DB.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %s (time, username, model, pk, action, changes) VALUES (%s)",
+			s.Table, strings.Join(placeholders, ", ")),
+		entry.Time.UTC(), entry.Username, entry.Model, entry.PK, entry.Action, string(changes))
+	return err
+}
+
+func (s *SQL) Entries(ctx context.Context, model string, pk string, limit int) ([]godmin.AuditEntry, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if model != "" {
+		args = append(args, model)
+		conditions = append(conditions, "model = "+s.Placeholder(len(args)))
+	}
+	if pk != "" {
+		args = append(args, pk)
+		conditions = append(conditions, "pk = "+s.Placeholder(len(args)))
+	}
+	query := fmt.Sprintf("SELECT time, username, model, pk, action, changes FROM %s", s.Table)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY time DESC"
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []godmin.AuditEntry
+	for rows.Next() {
+		var (
+			entry   godmin.AuditEntry
+			changes string
+		)
+		if err := rows.Scan(&entry.Time, &entry.Username, &entry.Model, &entry.PK, &entry.Action, &changes); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+			return nil, err
+		}
+		entry.Time = entry.Time.In(time.Local)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// SQLVersions is a VersionStore storing versions in a database table created along the lines of
+//
+//	CREATE TABLE godmin_versions (
+//		model    VARCHAR(255) NOT NULL,
+//		pk       VARCHAR(255) NOT NULL,
+//		number   INTEGER NOT NULL,
+//		time     TIMESTAMP NOT NULL,
+//		username VARCHAR(255) NOT NULL,
+//		vals     TEXT NOT NULL, -- JSON
+//		PRIMARY KEY (model, pk, number)
+//	);
+type SQLVersions struct {
+	DB          *sql.DB
+	Table       string
+	Placeholder func(n int) string // the nth query parameter, starting from 1, see the sqlparam package
+}
+
+// NewSQLVersions returns an SQLVersions VersionStore storing versions in table, using "?" query parameters.
+func NewSQLVersions(db *sql.DB, table string) *SQLVersions {
+	return &SQLVersions{DB: db, Table: table, Placeholder: sqlparam.Question}
+}
+
+// the number of times AddVersion tries to insert a version that concurrent saves keep numbering first
+const versionAttempts = 5
+
+// AddVersion numbers the version in the INSERT itself, so concurrent saves can only clash on
+// the primary key, and tries again with the next number when they do.
+func (s *SQLVersions) AddVersion(ctx context.Context, version godmin.Version) error {
+	values, err := json.Marshal(version.Values)
+	if err != nil {
+		return err
+	}
+	p := make([]string, 7)
+	for i := range p {
+		p[i] = s.Placeholder(i + 1)
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (model, pk, number, time, username, vals) "+
+		"SELECT %s, %s, COALESCE(MAX(number), 0) + 1, %s, %s, %s FROM %s WHERE model = %s AND pk = %s",
+		s.Table, p[0], p[1], p[2], p[3], p[4], s.Table, p[5], p[6])
+	for attempt := 0; attempt < versionAttempts; attempt++ {
+		_, err = s.DB.ExecContext(ctx, stmt, version.Model, version.PK, version.Time.UTC(), version.Username, string(values),
+			version.Model, version.PK)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+func (s *SQLVersions) Versions(ctx context.Context, model string, pk string) ([]godmin.Version, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		fmt.Sprintf("SELECT number, time, username, vals FROM %s WHERE model = %s AND pk = %s ORDER BY number DESC",
+			s.Table, s.Placeholder(1), s.Placeholder(2)),
+		model, pk)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var versions []godmin.Version
+	for rows.Next() {
+		version := godmin.Version{Model: model, PK: pk}
+		var values string
+		if err := rows.Scan(&version.Number, &version.Time, &version.Username, &values); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(values), &version.Values); err != nil {
+			return nil, err
+		}
+		version.Time = version.Time.In(time.Local)
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/gpitfield/godmin"
+	"github.com/gpitfield/godmin/internal/sqltest"
+)
+
+func TestSQL(t *testing.T) {
+	db := sqltest.Open(t, `CREATE TABLE godmin_audit (
+		time     TIMESTAMP NOT NULL,
+		username VARCHAR(255) NOT NULL,
+		model    VARCHAR(255) NOT NULL,
+		pk       VARCHAR(255) NOT NULL,
+		action   VARCHAR(255) NOT NULL,
+		changes  TEXT NOT NULL
+	)`)
+	testAuditLog(t, NewSQL(db, "godmin_audit"))
+}
+
+func TestSQLVersions(t *testing.T) {
+	db := sqltest.Open(t, `CREATE TABLE godmin_versions (
+		model    VARCHAR(255) NOT NULL,
+		pk       VARCHAR(255) NOT NULL,
+		number   INTEGER NOT NULL,
+		time     TIMESTAMP NOT NULL,
+		username VARCHAR(255) NOT NULL,
+		vals     TEXT NOT NULL,
+		PRIMARY KEY (model, pk, number)
+	)`)
+	store := NewSQLVersions(db, "godmin_versions")
+	testVersionStore(t, store)
+
+	// concurrent saves each get their own number
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := store.AddVersion(context.Background(), godmin.Version{Model: "Post", PK: "3"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	versions, err := store.Versions(context.Background(), "Post", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 10 || versions[0].Number != 10 || versions[9].Number != 1 {
+		t.Errorf("got %d versions numbered %d to %d", len(versions), versions[len(versions)-1].Number, versions[0].Number)
+	}
+}
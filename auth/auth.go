@@ -0,0 +1,260 @@
+// Package auth provides an optional godmin.Authenticator with its own login and logout
+// pages, checking bcrypt or argon2id password hashes against a pluggable UserStore and
+// keeping users logged in with signed session cookies.
+//
+//	authenticator := auth.New(store, []byte(os.Getenv("ADMIN_SESSION_SECRET")))
+//	admin.SetAuthenticator(authenticator)
+//	admin.Routes(r.Group(admin.AdminPath())) // also mounts /login and /logout
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpitfield/godmin"
+)
+
+// User is an admin account as kept in a UserStore.
+type User struct {
+	Username     string
+	AccountID    string
+	PasswordHash string // bcrypt or argon2id hash, see CheckPassword
+	Disabled     bool
+}
+
+// ErrUnknownUser is returned by a UserStore when there's no user with the requested username.
+var ErrUnknownUser = errors.New("auth: unknown user")
+
+// UserStore looks up admin users by username.
+type UserStore interface {
+	User(ctx context.Context, username string) (user *User, err error)
+}
+
+// MemoryStore is a UserStore holding users in memory, keyed by username.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	users map[string]*User
+}
+
+// NewMemoryStore returns a MemoryStore holding users.
+func NewMemoryStore(users ...*User) *MemoryStore {
+	s := &MemoryStore{users: make(map[string]*User)}
+	for _, user := range users {
+		s.Add(user)
+	}
+	return s
+}
+
+// Add adds or replaces a user.
+func (s *MemoryStore) Add(user *User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.Username] = user
+}
+
+func (s *MemoryStore) User(ctx context.Context, username string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, exists := s.users[username]
+	if !exists {
+		return nil, ErrUnknownUser
+	}
+	return user, nil
+}
+
+// SessionAuthenticator is a godmin.Authenticator that logs users in with a username and
+// password and keeps them logged in with a signed session cookie. Sessions end after
+// IdleTimeout without a request, or AbsoluteTimeout after logging in. Create it with New.
+type SessionAuthenticator struct {
+	Store           UserStore
+	Secret          []byte        // key the session cookies are signed with, at least 32 random bytes
+	IdleTimeout     time.Duration // defaults to 30 minutes
+	AbsoluteTimeout time.Duration // defaults to 12 hours
+	CookieName      string        // defaults to "godmin_session"
+	Secure          bool          // only send the session cookie over HTTPS
+	// optional privilege check for logged in users, who have every privilege if it's nil
+	Privileges func(c *gin.Context, collection string, action string, ids []string) (ok bool)
+
+	admin *godmin.Admin
+	now   func() time.Time
+}
+
+// MinSecretLength is the shortest session secret New accepts.
+const MinSecretLength = 32
+
+// New returns a SessionAuthenticator checking passwords against store and signing sessions with secret.
+// It panics if the secret is shorter than MinSecretLength bytes.
+func New(store UserStore, secret []byte) *SessionAuthenticator {
+	if len(secret) < MinSecretLength {
+		panic(fmt.Sprintf("auth: the session secret must be at least %d bytes, got %d", MinSecretLength, len(secret)))
+	}
+	return &SessionAuthenticator{
+		Store:           store,
+		Secret:          secret,
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 12 * time.Hour,
+		CookieName:      "godmin_session",
+		now:             time.Now,
+	}
+}
+
+// dummy hash compared against when the user doesn't exist, so both cases take as long
+var dummyHash, _ = HashPassword("godmin dummy password")
+
+// IsAdmin validates the session cookie, setting the "username" and "accountId" context values
+// expected by godmin, and refreshes the session's idle timeout. The user is looked up again
+// whenever the cookie is reissued, ending the session if they've been disabled or removed.
+func (s *SessionAuthenticator) IsAdmin(c *gin.Context) (ok bool) {
+	cookie, err := c.Cookie(s.CookieName)
+	if err != nil || cookie == "" {
+		return false
+	}
+	now := s.clock()
+	sess, err := decodeSession(cookie, s.Secret, now, s.IdleTimeout, s.AbsoluteTimeout)
+	if err != nil {
+		s.clearCookie(c)
+		return false
+	}
+	// only reissue the cookie, and check the user is still allowed in, occasionally rather than on every request
+	if now.Sub(time.Unix(sess.Seen, 0)) > time.Minute {
+		user, err := s.Store.User(c.Request.Context(), sess.Username)
+		if err != nil && !errors.Is(err, ErrUnknownUser) {
+			c.Error(err)
+		}
+		if err != nil || user == nil || user.Disabled {
+			s.clearCookie(c)
+			return false
+		}
+		sess.AccountID = user.AccountID
+		sess.Seen = now.Unix()
+		s.setCookie(c, sess)
+	}
+	c.Set("username", sess.Username)
+	if sess.AccountID != "" {
+		c.Set("accountId", sess.AccountID)
+	}
+	return true
+}
+
+// HasPrivilege defers to Privileges if set, otherwise every logged in user has every privilege.
+func (s *SessionAuthenticator) HasPrivilege(c *gin.Context, collection string, action string, ids []string) (ok bool) {
+	if s.Privileges == nil {
+		return true
+	}
+	return s.Privileges(c, collection, action, ids)
+}
+
+// Routes mounts the login and logout pages, and points the admin's login and logout links at them.
+func (s *SessionAuthenticator) Routes(a *godmin.Admin, r *gin.RouterGroup) {
+	s.admin = a
+	a.SetLoginURL(strings.TrimSuffix(a.AdminPath(), "/") + "/login")
+	a.SetLogoutURL(strings.TrimSuffix(a.AdminPath(), "/") + "/logout")
+	r.GET("/login", s.loginForm)
+	r.POST("/login", s.login)
+	r.GET("/logout", s.logout)
+	r.POST("/logout", s.logout)
+}
+
+func (s *SessionAuthenticator) loginForm(c *gin.Context) {
+	s.admin.Render(c, http.StatusOK, "admin/login.html", gin.H{"next": s.next(c.Query("next"))})
+}
+
+func (s *SessionAuthenticator) login(c *gin.Context) {
+	username := strings.TrimSpace(c.PostForm("username"))
+	password := c.PostForm("password")
+	next := s.next(c.PostForm("next"))
+
+	user, err := s.Store.User(c.Request.Context(), username)
+	hash := dummyHash
+	if err == nil && user != nil {
+		hash = user.PasswordHash
+	}
+	matched, checkErr := CheckPassword(hash, password)
+	if err != nil && !errors.Is(err, ErrUnknownUser) {
+		c.Error(err)
+	}
+	if checkErr != nil {
+		c.Error(checkErr)
+	}
+	if err != nil || user == nil || user.Disabled || !matched {
+		s.admin.Render(c, http.StatusUnauthorized, "admin/login.html",
+			gin.H{"next": next, "login": username, "error": "Incorrect username or password."})
+		return
+	}
+	now := s.clock().Unix()
+	s.setCookie(c, session{Username: user.Username, AccountID: user.AccountID, Issued: now, Seen: now})
+	c.Redirect(http.StatusFound, next)
+}
+
+func (s *SessionAuthenticator) logout(c *gin.Context) {
+	s.clearCookie(c)
+	s.admin.AddFlash(c, godmin.FlashInfo, "You have been logged out.")
+	c.Redirect(http.StatusFound, strings.TrimSuffix(s.admin.AdminPath(), "/")+"/login")
+}
+
+// only redirect to the admin's own pages after logging in. Browsers drop tabs and newlines
+// from URLs and treat backslashes as slashes, so next mustn't contain any.
+func (s *SessionAuthenticator) next(next string) string {
+	adminPath := s.admin.AdminPath()
+	if strings.Contains(next, `\`) || strings.IndexFunc(next, unicode.IsControl) >= 0 {
+		return adminPath
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" {
+		return adminPath
+	}
+	if u.Path != adminPath && !strings.HasPrefix(u.Path, strings.TrimSuffix(adminPath, "/")+"/") {
+		return adminPath
+	}
+	return next
+}
+
+func (s *SessionAuthenticator) setCookie(c *gin.Context, sess session) {
+	value, err := sess.encode(s.Secret)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     s.CookieName,
+		Value:    value,
+		Path:     s.cookiePath(),
+		MaxAge:   int(s.AbsoluteTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   s.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (s *SessionAuthenticator) clearCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     s.CookieName,
+		Path:     s.cookiePath(),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (s *SessionAuthenticator) clock() time.Time {
+	if s.now == nil {
+		return time.Now()
+	}
+	return s.now()
+}
+
+func (s *SessionAuthenticator) cookiePath() string {
+	if s.admin == nil {
+		return "/"
+	}
+	return s.admin.AdminPath()
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpitfield/godmin"
+	"golang.org/x/crypto/argon2"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := []byte("somesaltsomesalt")
+	argon2Hash := fmt.Sprintf("$argon2id$v=19$m=65536,t=1,p=1$%s$%s", base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte("hunter2"), salt, 1, 65536, 1, 32)))
+	for _, h := range []string{hash, argon2Hash} {
+		if ok, err := CheckPassword(h, "hunter2"); !ok || err != nil {
+			t.Errorf("%v: correct password rejected: %v", h, err)
+		}
+		if ok, err := CheckPassword(h, "hunter3"); ok || err != nil {
+			t.Errorf("%v: wrong password accepted: %v", h, err)
+		}
+	}
+	if _, err := CheckPassword("plaintext", "plaintext"); err != ErrUnsupportedHash {
+		t.Errorf("expected ErrUnsupportedHash, got %v", err)
+	}
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	secret := []byte("secret")
+	start := time.Unix(1000000, 0)
+	cookie, _ := session{Username: "alice", Issued: start.Unix(), Seen: start.Unix()}.encode(secret)
+	if _, err := decodeSession(cookie, secret, start.Add(time.Minute), time.Hour, 2*time.Hour); err != nil {
+		t.Errorf("valid session rejected: %v", err)
+	}
+	if _, err := decodeSession(cookie, secret, start.Add(61*time.Minute), time.Hour, 2*time.Hour); err == nil {
+		t.Error("idle session accepted")
+	}
+	cookie, _ = session{Username: "alice", Issued: start.Unix(), Seen: start.Add(2 * time.Hour).Unix()}.encode(secret)
+	if _, err := decodeSession(cookie, secret, start.Add(121*time.Minute), time.Hour, 2*time.Hour); err == nil {
+		t.Error("session past its absolute timeout accepted")
+	}
+	if _, err := decodeSession(cookie, []byte("other"), start, time.Hour, 2*time.Hour); err == nil {
+		t.Error("session signed with another secret accepted")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hash, _ := HashPassword("hunter2")
+	store := NewMemoryStore(&User{Username: "alice", AccountID: "1", PasswordHash: hash})
+	authenticator := New(store, []byte("0123456789abcdef0123456789abcdef"))
+	now := time.Unix(1000000, 0)
+	authenticator.now = func() time.Time { return now }
+	admin := godmin.NewAdmin()
+	admin.SetAuthenticator(authenticator)
+	tmpl := template.Must(template.New("admin/login.html").Parse("{{.error}}"))
+	template.Must(tmpl.New("admin/index.html").Parse("index {{.username}}"))
+	template.Must(tmpl.New("admin/error.html").Parse("{{.error}}"))
+	r := gin.New()
+	r.SetHTMLTemplate(tmpl)
+	admin.Routes(r.Group("/admin"))
+
+	login := func(password string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {"alice"}, "password": {password}, "next": {"//evil.com"}, "csrf_token": {"t"}}
+		req := httptest.NewRequest("POST", "/admin/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "godmin_csrf", Value: "t"})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := login("wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password got status %d", w.Code)
+	}
+	w := login("hunter2")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/admin" {
+		t.Fatalf("login got status %d redirecting to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	cookies := w.Result().Cookies()
+	index := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/admin/", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := index(); w.Body.String() != "index alice" {
+		t.Errorf("session not recognised, got %q", w.Body.String())
+	}
+
+	// disabling the user ends their session once the cookie is due to be reissued
+	store.Add(&User{Username: "alice", AccountID: "1", PasswordHash: hash, Disabled: true})
+	if w := index(); w.Body.String() != "index alice" {
+		t.Errorf("session checked before it was due, got %q", w.Body.String())
+	}
+	now = now.Add(2 * time.Minute)
+	if w := index(); w.Body.String() == "index alice" {
+		t.Error("disabled user still logged in")
+	}
+}
+
+func TestShortSecret(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("short secret accepted")
+		}
+	}()
+	New(NewMemoryStore(), []byte("secret"))
+}
+
+func TestNext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator := New(NewMemoryStore(), []byte("0123456789abcdef0123456789abcdef"))
+	admin := godmin.NewAdmin()
+	admin.SetAuthenticator(authenticator)
+	admin.Routes(gin.New().Group("/admin"))
+	for next, want := range map[string]string{
+		"/admin/users/1?tab=a": "/admin/users/1?tab=a",
+		"/admin":               "/admin",
+		"//evil.com":           "/admin",
+		"/\\evil.com":          "/admin",
+		"/\t/evil.com":         "/admin",
+		"/\n/evil.com":         "/admin",
+		"https://evil.com":     "/admin",
+		"javascript:alert(1)":  "/admin",
+		"/administrator":       "/admin",
+		"/other":               "/admin",
+		"":                     "/admin",
+	} {
+		if got := authenticator.next(next); got != want {
+			t.Errorf("next %q redirected to %q", next, got)
+		}
+	}
+}
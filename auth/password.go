@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnsupportedHash is returned for password hashes that are neither bcrypt nor argon2id.
+var ErrUnsupportedHash = errors.New("auth: unsupported password hash")
+
+// HashPassword returns a bcrypt hash of password for storing in a User.
+func HashPassword(password string) (hash string, err error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(b), err
+}
+
+// CheckPassword reports whether password matches hash, which may be a bcrypt hash
+// ("$2a$...") or an argon2id hash in the PHC string format
+// ("$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>", base64 without padding).
+func CheckPassword(hash string, password string) (ok bool, err error) {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return checkArgon2id(hash, password)
+	}
+	return false, ErrUnsupportedHash
+}
+
+func checkArgon2id(hash string, password string) (ok bool, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, fmt.Errorf("%w: malformed argon2id hash", ErrUnsupportedHash)
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, fmt.Errorf("%w: argon2 version %q", ErrUnsupportedHash, parts[2])
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, fmt.Errorf("%w: argon2 parameters %q", ErrUnsupportedHash, parts[3])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("%w: argon2 salt: %v", ErrUnsupportedHash, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, fmt.Errorf("%w: argon2 key: %v", ErrUnsupportedHash, err)
+	}
+	derived := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(derived, key) == 1, nil
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var errInvalidSession = errors.New("auth: invalid session")
+
+// session is the payload of the signed session cookie
+type session struct {
+	Username  string `json:"u"`
+	AccountID string `json:"a,omitempty"`
+	Issued    int64  `json:"i"` // unix time the user logged in
+	Seen      int64  `json:"s"` // unix time of the user's last request
+}
+
+// encode the session as base64(payload).base64(hmac)
+func (s session) encode(secret []byte) (string, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(sign(secret, encoded)), nil
+}
+
+func sign(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// decode a session cookie, checking its signature and timeouts
+func decodeSession(cookie string, secret []byte, now time.Time, idle, absolute time.Duration) (s session, err error) {
+	i := strings.IndexByte(cookie, '.')
+	if i < 0 {
+		return s, errInvalidSession
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(cookie[i+1:])
+	if err != nil || !hmac.Equal(signature, sign(secret, cookie[:i])) {
+		return s, errInvalidSession
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(cookie[:i])
+	if err != nil {
+		return s, errInvalidSession
+	}
+	if err = json.Unmarshal(payload, &s); err != nil || s.Username == "" {
+		return s, errInvalidSession
+	}
+	if now.Sub(time.Unix(s.Issued, 0)) > absolute || now.Sub(time.Unix(s.Seen, 0)) > idle {
+		return s, errInvalidSession
+	}
+	return s, nil
+}
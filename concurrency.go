@@ -0,0 +1,97 @@
+package godmin
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	versionField  = "_version"  // change form field holding the version of the object the form was rendered from
+	originalField = "_original" // change form field holding the values the form was rendered with
+)
+
+// the version of an object with the given values, as rendered by ValuesMapper:
+// the value of the VersionField if there is one, otherwise a hash of all the values
+func (m *ModelAdmin) versionOf(values map[string]string) string {
+	if m.VersionField != "" {
+		return values[m.VersionField]
+	}
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	h := sha256.New()
+	for _, field := range fields {
+		fmt.Fprintf(h, "%q:%q\n", field, values[field])
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func encodeValues(values map[string]string) string {
+	b, _ := json.Marshal(values)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeValues(s string) map[string]string {
+	values := make(map[string]string)
+	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		json.Unmarshal(b, &values)
+	}
+	return values
+}
+
+// fieldConflict shows how a field was changed by the admin user and by whoever saved the object in the meantime
+type fieldConflict struct {
+	Field    string
+	Original string // the value the user started from, if known
+	Theirs   string // the value saved in the meantime
+	Yours    string // the value the user submitted
+	Changed  bool   // whether the user changed the field
+	Conflict bool   // whether both changed the field, to different values
+}
+
+// compare the submitted values with the object's values when its change form was rendered and its current values
+func fieldConflicts(original, current, submitted map[string]string) []fieldConflict {
+	var conflicts []fieldConflict
+	for field, theirs := range current {
+		yours, exists := submitted[field]
+		if !exists {
+			yours = theirs
+		}
+		before, known := original[field]
+		if !known {
+			before = theirs
+		}
+		fc := fieldConflict{Field: field, Original: before, Theirs: theirs, Yours: yours}
+		fc.Changed = yours != before
+		fc.Conflict = fc.Changed && theirs != before && theirs != yours
+		if fc.Changed || theirs != before {
+			conflicts = append(conflicts, fc)
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Field < conflicts[j].Field })
+	return conflicts
+}
+
+// show the changes saved since the user's change form was rendered, letting them merge them with
+// their own changes or overwrite them
+func (a *Admin) renderConflict(c *gin.Context, modelAdmin ModelAdmin, pk string, original, current, submitted map[string]string) {
+	a.reportError(c, ErrConflict)
+	version := modelAdmin.versionOf(current)
+	current = visibleValues(modelAdmin, current)
+	dot := a.defaultDot(c)
+	dot["modelAdmin"] = modelAdmin
+	dot["pk"] = pk
+	dot["conflicts"] = fieldConflicts(visibleValues(modelAdmin, original), current, submitted)
+	dot["submitted"] = submitted
+	dot["version"] = version
+	dot["original"] = encodeValues(current)
+	c.HTML(http.StatusConflict, "admin/conflict.html", dot)
+}
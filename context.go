@@ -0,0 +1,129 @@
+package godmin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextAccessor is the context-aware version of Accessor. The context passed to each
+// method is cancelled when the admin user's request goes away, and carries the
+// authenticated user, available through Username and AccountID.
+type ContextAccessor interface {
+	// Return an single empty instance of the model
+	Prototype() (result interface{})
+	// Must return a single struct of the administered type
+	Get(ctx context.Context, pk string) (result interface{}, err error)
+	// Must return a slice of structs of the administered type
+	List(ctx context.Context, count, page int, order []Order) (results interface{}, err error)
+	Count(ctx context.Context) (count int, err error)
+	Upsert(ctx context.Context, pk string, values map[string][]string) (outPk string, err error)
+	DeletePK(ctx context.Context, pk string) (err error)
+}
+
+// legacyAccessor adapts an Accessor to the ContextAccessor interface, ignoring the context
+type legacyAccessor struct {
+	Accessor
+}
+
+func (l legacyAccessor) Get(ctx context.Context, pk string) (result interface{}, err error) {
+	return l.Accessor.Get(pk)
+}
+
+func (l legacyAccessor) List(ctx context.Context, count, page int, order []Order) (results interface{}, err error) {
+	return l.Accessor.List(count, page, order)
+}
+
+func (l legacyAccessor) Count(ctx context.Context) (count int, err error) {
+	return l.Accessor.Count()
+}
+
+func (l legacyAccessor) Upsert(ctx context.Context, pk string, values map[string][]string) (outPk string, err error) {
+	return l.Accessor.Upsert(pk, values)
+}
+
+func (l legacyAccessor) DeletePK(ctx context.Context, pk string) (err error) {
+	return l.Accessor.DeletePK(pk)
+}
+
+func (l legacyAccessor) filterAccessor() FilterAccessor {
+	fa, _ := l.Accessor.(FilterAccessor)
+	return fa
+}
+
+func (l legacyAccessor) saver() ContextSaver {
+	switch saver := l.Accessor.(type) {
+	case Saver:
+		return legacySaver{saver}
+	case ContextSaver:
+		return saver
+	}
+	return nil
+}
+
+func (l legacyAccessor) hooks() interface{} {
+	return l.Accessor
+}
+
+// AdaptAccessor wraps an Accessor so it can be used where a ContextAccessor is expected,
+// along with its Saver, FilterAccessor and object hook methods if it has them.
+func AdaptAccessor(a Accessor) ContextAccessor {
+	return legacyAccessor{a}
+}
+
+type contextKey int
+
+const (
+	usernameContextKey contextKey = iota
+	accountIdContextKey
+)
+
+// build the context handed to accessors, searchers and actions from the gin request context
+func requestContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	if username, exists := c.Get(usernameKey); exists {
+		ctx = context.WithValue(ctx, usernameContextKey, username)
+	}
+	if accountId, exists := c.Get(accountIdKey); exists {
+		ctx = context.WithValue(ctx, accountIdContextKey, accountId)
+	}
+	return ctx
+}
+
+// Username returns the username of the admin user performing the request, as set by the Authenticator.
+func Username(ctx context.Context) string {
+	username := ctx.Value(usernameContextKey)
+	if username == nil {
+		return ""
+	}
+	if s, ok := username.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", username)
+}
+
+// AccountID returns the accountId of the admin user performing the request, as set by the Authenticator.
+func AccountID(ctx context.Context) interface{} {
+	return ctx.Value(accountIdContextKey)
+}
+
+// search using SearchContext if provided, falling back to Search
+func (s *Searcher) search(ctx context.Context, count, page int, query string, order []Order) (results interface{}, totalCount int, err error) {
+	if s.SearchContext != nil {
+		return s.SearchContext(ctx, count, page, query, order)
+	}
+	return s.Search(count, page, query, order)
+}
+
+// run the action using MessageAction or ActionContext if provided, falling back to Action
+func (a *AdminAction) run(ctx context.Context, values *url.Values) (message string, err error) {
+	if a.MessageAction != nil {
+		return a.MessageAction(ctx, values)
+	}
+	if a.ActionContext != nil {
+		return "", a.ActionContext(ctx, values)
+	}
+	return "", a.Action(values)
+}
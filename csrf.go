@@ -0,0 +1,84 @@
+package godmin
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSRFStore issues and checks the tokens that protect admin forms against cross-site request forgery.
+type CSRFStore interface {
+	// Token returns the token to embed in forms for the requesting user, creating one if needed.
+	Token(c *gin.Context) string
+	// Valid reports whether the token submitted with the request was issued to the requesting user.
+	Valid(c *gin.Context, token string) bool
+}
+
+const (
+	csrfField      = "csrf_token"   // form field the token is submitted in
+	csrfHeader     = "X-CSRF-Token" // header the token can be submitted in instead
+	csrfCookie     = "godmin_csrf"
+	csrfContextKey = "godmin.csrf"
+)
+
+// cookieCSRFStore is the default CSRFStore, keeping a random token in a cookie
+// that's compared against the one submitted with the form
+type cookieCSRFStore struct {
+	admin *Admin
+}
+
+func (s cookieCSRFStore) Token(c *gin.Context) string {
+	if token, exists := c.Get(csrfContextKey); exists {
+		return token.(string)
+	}
+	token, err := c.Cookie(csrfCookie)
+	if err != nil || token == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		token = base64.RawURLEncoding.EncodeToString(b)
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     csrfCookie,
+			Value:    token,
+			Path:     s.admin.adminPath,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	c.Set(csrfContextKey, token)
+	return token
+}
+
+func (s cookieCSRFStore) Valid(c *gin.Context, token string) bool {
+	expected, err := c.Cookie(csrfCookie)
+	if err != nil || expected == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
+// middleware rejecting unsafe requests that don't carry a valid CSRF token
+func (a *Admin) csrfProtect(c *gin.Context) {
+	switch c.Request.Method {
+	case "GET", "HEAD", "OPTIONS":
+		c.Next()
+		return
+	}
+	token := c.GetHeader(csrfHeader)
+	if token == "" {
+		token = c.PostForm(csrfField)
+	}
+	if !a.csrf.Valid(c, token) {
+		a.renderError(c, ErrCSRF)
+		c.Abort()
+		return
+	}
+	// the token isn't part of the submitted object
+	delete(c.Request.Form, csrfField)
+	delete(c.Request.PostForm, csrfField)
+	c.Next()
+}
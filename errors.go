@@ -0,0 +1,118 @@
+package godmin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors that Accessor implementations can return, or wrap with fmt.Errorf("...: %w", err),
+// to have the admin respond with the matching HTTP status.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrInvalidPK    = errors.New("invalid ID")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrForbidden    = errors.New("forbidden")
+	ErrBadRequest   = errors.New("bad request")
+	ErrVetoed       = errors.New("vetoed by hook") // matched by errors returned from hooks that veto an operation
+	ErrCSRF         = errors.New("invalid CSRF token")
+	ErrUnauthorized = errors.New("not logged in") // the Authenticator found no admin user logged in
+)
+
+// wrap an error from parsing the request so it maps to a 400
+func badRequest(err error) error {
+	return fmt.Errorf("%w: %v", ErrBadRequest, err)
+}
+
+// ValidationError reports problems with individual fields, keyed by field name.
+// It matches ErrValidation with errors.Is.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	msgs := make([]string, len(fields))
+	for i, field := range fields {
+		msgs[i] = fmt.Sprintf("%s: %s", field, e.Fields[field])
+	}
+	return fmt.Sprintf("%v: %s", ErrValidation, strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// legacy Accessors signal missing objects with these plain error strings
+var legacyErrors = map[string]error{
+	"Not Found":  ErrNotFound,
+	"Invalid ID": ErrInvalidPK,
+}
+
+// errorStatus maps an error returned by an Accessor to an HTTP status code
+// and a message suitable for showing to the admin user.
+func errorStatus(err error) (status int, message string) {
+	if legacy, exists := legacyErrors[err.Error()]; exists {
+		err = legacy
+	}
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, "Not found."
+	case errors.Is(err, ErrInvalidPK):
+		return http.StatusNotFound, "Invalid ID."
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, ErrValidation):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, ErrVetoed):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, ErrBadRequest):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, ErrCSRF):
+		return http.StatusForbidden, "Your form has expired, please reload the page and try again."
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized, "Please log in with an admin account."
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden, "You don't have the necessary permissions to do that."
+	}
+	return http.StatusInternalServerError, "Something went wrong, the error has been logged."
+}
+
+// log server errors and pass every error to the ErrorHandler if one is set,
+// returning the status and message to show the user
+func (a *Admin) reportError(c *gin.Context, err error) (status int, message string) {
+	status, message = errorStatus(err)
+	if status >= http.StatusInternalServerError {
+		a.logger.Printf("%v %v: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+	if a.errorHandler != nil {
+		a.errorHandler(c, err)
+	}
+	return
+}
+
+// render the error template with the status and message matching err
+func (a *Admin) renderError(c *gin.Context, err error) {
+	status, message := a.reportError(c, err)
+	dot := a.defaultDot(c)
+	dot["error"] = message
+	c.HTML(status, "admin/error.html", dot)
+}
+
+// render an error caused by the list view's query, linking back to the unsorted and unfiltered list
+func (a *Admin) renderListError(c *gin.Context, err error) {
+	status, message := a.reportError(c, err)
+	dot := a.defaultDot(c)
+	dot["error"] = message
+	dot["backURL"] = c.Request.URL.Path
+	c.HTML(status, "admin/error.html", dot)
+}
@@ -0,0 +1,333 @@
+package godmin
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilterKind is the kind of values a ListFilter narrows the list view down by.
+type FilterKind string
+
+const (
+	FilterBool        FilterKind = "bool"    // yes or no
+	FilterChoices     FilterKind = "choices" // one of the filter's Choices
+	FilterDateRange   FilterKind = "date"    // on or between two dates
+	FilterNumberRange FilterKind = "number"  // at least and/or at most a number
+	FilterNull        FilterKind = "null"    // empty or not
+	FilterCustom      FilterKind = "custom"  // one of the filter's Choices, turned into Conditions by the filter's Conditions func if set
+)
+
+// FilterChoice is a value a FilterChoices or FilterCustom ListFilter can be set to.
+type FilterChoice struct {
+	Value string
+	Label string
+}
+
+// ListFilter declares a filter shown in the list view's sidebar. The chosen values are
+// kept in the query string as f.<Field>=<value>, or f.<Field>.gte and f.<Field>.lte for ranges,
+// and passed to the accessor as Conditions.
+type ListFilter struct {
+	Field   string // the field filtered on, or for FilterCustom, a name for the filter
+	Title   string // optional title, defaults to the field's label
+	Kind    FilterKind
+	Choices []FilterChoice
+	// for FilterCustom, turns the chosen value into the conditions passed to the accessor
+	Conditions func(value string) (conditions []Condition, err error)
+}
+
+// Operator compares a field with a Condition's Value.
+type Operator string
+
+const (
+	OpEqual          Operator = "eq"
+	OpGreaterOrEqual Operator = "gte"
+	OpLess           Operator = "lt"
+	OpLessOrEqual    Operator = "lte"
+	OpIsNull         Operator = "isnull"  // the field is nil or empty, Value is unused
+	OpNotNull        Operator = "notnull" // the field is neither nil nor empty, Value is unused
+)
+
+// Condition restricts the objects listed to those whose Field compares with Value as per Op.
+// Field is the field's column if the ModelAdmin maps it in FieldColumns.
+// Value is a bool for FilterBool, a string for FilterChoices, a time.Time for FilterDateRange
+// and a float64 for FilterNumberRange.
+type Condition struct {
+	Field string
+	Op    Operator
+	Value interface{}
+}
+
+// FilterAccessor is optionally implemented by accessors to list and count the objects matching
+// the conditions chosen in the list view's filters, all of which must hold.
+// The filters are only offered for accessors implementing it, and while searching,
+// for Searchers with a SearchContext, which reads the conditions with FiltersFromContext.
+type FilterAccessor interface {
+	ListFiltered(ctx context.Context, count, page int, order []Order, conditions []Condition) (results interface{}, err error)
+	CountFiltered(ctx context.Context, conditions []Condition) (count int, err error)
+}
+
+// the ModelAdmin's accessor as a FilterAccessor, or nil if it doesn't implement one
+func (m *ModelAdmin) filterAccessor() FilterAccessor {
+	accessor := m.accessor()
+	if optional, ok := accessor.(optionalAccessor); ok {
+		return optional.filterAccessor()
+	}
+	fa, _ := accessor.(FilterAccessor)
+	return fa
+}
+
+// whether the conditions of the list view's filters can be applied, by the FilterAccessor,
+// or by the Searcher's SearchContext if there's a search query
+func (m *ModelAdmin) canFilter(query string) bool {
+	if m.Searcher != nil && query != "" {
+		return m.Searcher.SearchContext != nil
+	}
+	return m.filterAccessor() != nil
+}
+
+type filtersContextKey struct{}
+
+// FiltersFromContext returns the conditions chosen in the list view's filters, e.g. for a Searcher.
+func FiltersFromContext(ctx context.Context) []Condition {
+	conditions, _ := ctx.Value(filtersContextKey{}).([]Condition)
+	return conditions
+}
+
+const filterPrefix = "f."
+
+const dateFormat = "2006-01-02"
+
+// the filters the user may use, leaving out those on fields hidden from them
+func (m *ModelAdmin) listFilters() []ListFilter {
+	var filters []ListFilter
+	for _, filter := range m.ListFilters {
+		if !m.OmitFields[filter.Field] {
+			filters = append(filters, filter)
+		}
+	}
+	return filters
+}
+
+// parse the filter values in the query into conditions, rejecting parameters of unknown filters
+func (m *ModelAdmin) parseFilters(query url.Values) (conditions []Condition, err error) {
+	known := make(map[string]bool)
+	for _, filter := range m.listFilters() {
+		key := filterPrefix + filter.Field
+		if filter.Kind == FilterDateRange || filter.Kind == FilterNumberRange {
+			known[key+".gte"], known[key+".lte"] = true, true
+		} else {
+			known[key] = true
+		}
+	}
+	for key := range query {
+		if strings.HasPrefix(key, filterPrefix) && !known[key] {
+			return nil, fmt.Errorf("can't filter by %q", strings.TrimPrefix(key, filterPrefix))
+		}
+	}
+	for _, filter := range m.listFilters() {
+		key := filterPrefix + filter.Field
+		value := query.Get(key)
+		switch filter.Kind {
+		case FilterBool:
+			if value == "" {
+				continue
+			}
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("%v must be true or false", key)
+			}
+			conditions = append(conditions, Condition{filter.Field, OpEqual, b})
+		case FilterChoices:
+			if value == "" {
+				continue
+			}
+			if !filter.hasChoice(value) {
+				return nil, fmt.Errorf("%v can't be %q", key, value)
+			}
+			conditions = append(conditions, Condition{filter.Field, OpEqual, value})
+		case FilterNull:
+			switch value {
+			case "":
+			case "null":
+				conditions = append(conditions, Condition{Field: filter.Field, Op: OpIsNull})
+			case "notnull":
+				conditions = append(conditions, Condition{Field: filter.Field, Op: OpNotNull})
+			default:
+				return nil, fmt.Errorf("%v must be null or notnull", key)
+			}
+		case FilterDateRange:
+			if from := query.Get(key + ".gte"); from != "" {
+				t, err := time.ParseInLocation(dateFormat, from, time.Local)
+				if err != nil {
+					return nil, fmt.Errorf("%v.gte must be a date like %v", key, dateFormat)
+				}
+				conditions = append(conditions, Condition{filter.Field, OpGreaterOrEqual, t})
+			}
+			if to := query.Get(key + ".lte"); to != "" {
+				t, err := time.ParseInLocation(dateFormat, to, time.Local)
+				if err != nil {
+					return nil, fmt.Errorf("%v.lte must be a date like %v", key, dateFormat)
+				}
+				// include the whole of the last day
+				conditions = append(conditions, Condition{filter.Field, OpLess, t.AddDate(0, 0, 1)})
+			}
+		case FilterNumberRange:
+			if from := query.Get(key + ".gte"); from != "" {
+				n, err := strconv.ParseFloat(from, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%v.gte must be a number", key)
+				}
+				conditions = append(conditions, Condition{filter.Field, OpGreaterOrEqual, n})
+			}
+			if to := query.Get(key + ".lte"); to != "" {
+				n, err := strconv.ParseFloat(to, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%v.lte must be a number", key)
+				}
+				conditions = append(conditions, Condition{filter.Field, OpLessOrEqual, n})
+			}
+		case FilterCustom:
+			if value == "" {
+				continue
+			}
+			if !filter.hasChoice(value) {
+				return nil, fmt.Errorf("%v can't be %q", key, value)
+			}
+			if filter.Conditions == nil {
+				conditions = append(conditions, Condition{filter.Field, OpEqual, value})
+				continue
+			}
+			custom, err := filter.Conditions(value)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, custom...)
+		}
+	}
+	return conditions, nil
+}
+
+// the conditions with their fields mapped to columns
+func (m *ModelAdmin) columnConditions(conditions []Condition) []Condition {
+	if len(m.FieldColumns) == 0 {
+		return conditions
+	}
+	columns := make([]Condition, len(conditions))
+	for i, condition := range conditions {
+		columns[i] = Condition{m.column(condition.Field), condition.Op, condition.Value}
+	}
+	return columns
+}
+
+func (f ListFilter) hasChoice(value string) bool {
+	for _, choice := range f.Choices {
+		if choice.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// the query parameters of the filters, for links from the list view
+func filterParams(query url.Values) url.Values {
+	params := make(url.Values)
+	for key, values := range query {
+		if strings.HasPrefix(key, filterPrefix) {
+			params[key] = values
+		}
+	}
+	return params
+}
+
+// filterLink is an option of a filter in the sidebar
+type filterLink struct {
+	Label    string
+	URL      template.URL
+	Selected bool
+}
+
+// filterView is a filter as rendered in the sidebar
+type filterView struct {
+	Title string
+	Links []filterLink
+	// for range filters, a form of two inputs of the type Input
+	Input            string
+	FromName, ToName string
+	From, To         string
+	Hidden           map[string]string // the other query parameters, kept when submitting the form
+	Field            string
+	Active           bool
+	ClearURL         template.URL
+}
+
+// the sidebar's filters, with links keeping the search query, sort order and other filters
+func (m *ModelAdmin) filterViews(query url.Values) []filterView {
+	var views []filterView
+	for _, filter := range m.listFilters() {
+		key := filterPrefix + filter.Field
+		view := filterView{Title: filter.Title, Field: filter.Field}
+		if view.Title == "" {
+			view.Title = filter.Field
+			if label := m.FieldLabels[filter.Field]; label != "" {
+				view.Title = label
+			}
+		}
+		// link to the list with the filter's parameters set to value, or removed if value is ""
+		link := func(label string, value string) filterLink {
+			q := copyValues(query)
+			q.Del("page")
+			q.Del(key)
+			q.Del(key + ".gte")
+			q.Del(key + ".lte")
+			if value != "" {
+				q.Set(key, value)
+			}
+			return filterLink{label, template.URL("?" + q.Encode()), query.Get(key) == value}
+		}
+		switch filter.Kind {
+		case FilterBool:
+			view.Links = []filterLink{link("All", ""), link("Yes", "true"), link("No", "false")}
+		case FilterNull:
+			view.Links = []filterLink{link("All", ""), link("Empty", "null"), link("Not empty", "notnull")}
+		case FilterChoices, FilterCustom:
+			view.Links = []filterLink{link("All", "")}
+			for _, choice := range filter.Choices {
+				label := choice.Label
+				if label == "" {
+					label = choice.Value
+				}
+				view.Links = append(view.Links, link(label, choice.Value))
+			}
+		case FilterDateRange, FilterNumberRange:
+			view.Input = "number"
+			if filter.Kind == FilterDateRange {
+				view.Input = "date"
+			}
+			view.FromName, view.ToName = key+".gte", key+".lte"
+			view.From, view.To = query.Get(view.FromName), query.Get(view.ToName)
+			view.Active = view.From != "" || view.To != ""
+			view.ClearURL = link("", "").URL
+			view.Hidden = make(map[string]string)
+			for k := range query {
+				if k != view.FromName && k != view.ToName && k != "page" {
+					view.Hidden[k] = query.Get(k)
+				}
+			}
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+func copyValues(in url.Values) url.Values {
+	out := make(url.Values, len(in))
+	for k, v := range in {
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package godmin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FlashLevel is the severity of a flash message, which controls how it's displayed.
+type FlashLevel string
+
+const (
+	FlashSuccess FlashLevel = "success"
+	FlashInfo    FlashLevel = "info"
+	FlashWarning FlashLevel = "warning"
+	FlashError   FlashLevel = "error"
+)
+
+// Flash is a message shown to the admin user on the next page they see.
+type Flash struct {
+	Level   FlashLevel
+	Message string
+}
+
+const (
+	flashCookie     = "godmin_flash"
+	flashContextKey = "godmin.flashes"
+	maxFlashes      = 10
+)
+
+// AddFlash queues a message to be shown on the next admin page rendered for the user,
+// whether in this response or after a redirect.
+func (a *Admin) AddFlash(c *gin.Context, level FlashLevel, message string) {
+	flashes := a.pendingFlashes(c)
+	flashes = append(flashes, Flash{level, message})
+	if len(flashes) > maxFlashes {
+		flashes = flashes[len(flashes)-maxFlashes:]
+	}
+	c.Set(flashContextKey, flashes)
+	encoded, err := json.Marshal(flashes)
+	if err != nil {
+		a.logger.Printf("error encoding flash messages: %v", err)
+		return
+	}
+	a.setFlashCookie(c, base64.URLEncoding.EncodeToString(encoded), 0)
+}
+
+// the flashes queued by this request, or carried over from the previous one in the cookie
+func (a *Admin) pendingFlashes(c *gin.Context) (flashes []Flash) {
+	if pending, exists := c.Get(flashContextKey); exists {
+		return pending.([]Flash)
+	}
+	cookie, err := c.Cookie(flashCookie)
+	if err != nil || cookie == "" {
+		return nil
+	}
+	if decoded, err := base64.URLEncoding.DecodeString(cookie); err == nil {
+		json.Unmarshal(decoded, &flashes)
+	}
+	return flashes
+}
+
+// popFlashes returns the pending flashes and clears them, as they're about to be shown
+func (a *Admin) popFlashes(c *gin.Context) (flashes []Flash) {
+	flashes = a.pendingFlashes(c)
+	c.Set(flashContextKey, []Flash(nil))
+	if cookie, err := c.Cookie(flashCookie); len(flashes) > 0 || (err == nil && cookie != "") {
+		a.setFlashCookie(c, "", -1)
+	}
+	return flashes
+}
+
+func (a *Admin) setFlashCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     flashCookie,
+		Value:    value,
+		Path:     a.adminPath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
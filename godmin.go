@@ -3,14 +3,16 @@
 package godmin
 
 import (
-	// "errors"
 	// "encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"math"
 	"net/http"
 	"net/url"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
@@ -27,6 +29,9 @@ type AdminAction struct {
 	ConfirmTitle   string
 	ConfirmMessage string
 	Action         func(values *url.Values) (err error)
+	ActionContext  func(ctx context.Context, values *url.Values) (err error) // used in place of Action if set
+	// used in place of Action and ActionContext if set, message is shown to the user on success
+	MessageAction func(ctx context.Context, values *url.Values) (message string, err error)
 }
 
 func lowerLettersOnly(r rune) rune {
@@ -54,6 +59,25 @@ func NewAdminAction(displayName string, confirm bool, confirmTitle string,
 	}
 }
 
+// NewContextAdminAction returns a pointer to a new AdminAction instance whose action receives the request context.
+func NewContextAdminAction(displayName string, confirm bool, confirmTitle string,
+	confirmMsg string, action func(ctx context.Context, values *url.Values) (err error)) *AdminAction {
+
+	adminAction := NewAdminAction(displayName, confirm, confirmTitle, confirmMsg, nil)
+	adminAction.ActionContext = action
+	return adminAction
+}
+
+// NewMessageAdminAction returns a pointer to a new AdminAction instance whose action
+// returns a message to show the user once it's done.
+func NewMessageAdminAction(displayName string, confirm bool, confirmTitle string,
+	confirmMsg string, action func(ctx context.Context, values *url.Values) (message string, err error)) *AdminAction {
+
+	adminAction := NewAdminAction(displayName, confirm, confirmTitle, confirmMsg, nil)
+	adminAction.MessageAction = action
+	return adminAction
+}
+
 // Authenticator manages admin rights. If no Authenticator is provided, the admin is completely open.
 // This is of course strongly not recommended.
 type Authenticator interface {
@@ -64,8 +88,43 @@ type Authenticator interface {
 	HasPrivilege(c *gin.Context, collection string, action string, ids []string) (ok bool)
 }
 
+// Actions passed to Authenticator.HasPrivilege, along with the primary keys of the objects
+// involved. The collection is the ModelName, or "" for the admin home page.
+// List actions are checked as their ActionPrivilege. Users who may view but not change
+// an object see its change form read-only.
+const (
+	PrivilegeView   = "read"
+	PrivilegeChange = "write"
+	PrivilegeAdd    = "create"
+	PrivilegeDelete = "delete"
+)
+
+// PrivilegeActionPrefix starts the privileges of list actions, keeping them apart from the privileges above.
+const PrivilegeActionPrefix = "action:"
+
+// ActionPrivilege returns the privilege checked for the list action with the identifier, e.g. "action:archive".
+func ActionPrivilege(identifier string) string {
+	return PrivilegeActionPrefix + identifier
+}
+
+// RouteAuthenticator is implemented by Authenticators that serve their own pages, such as a login form.
+// Routes is called with the admin's router group when the admin's Routes are set up.
+type RouteAuthenticator interface {
+	Authenticator
+	Routes(a *Admin, r *gin.RouterGroup)
+}
+
+// FieldAuthenticator is implemented by Authenticators that decide per request which fields of a model
+// the user may see and edit. The fields returned are hidden or made read-only on top of the ModelAdmin's
+// OmitFields and ReadOnlyFields, both on the admin pages and when accepting submitted forms.
+type FieldAuthenticator interface {
+	Authenticator
+	FieldPermissions(c *gin.Context, collection string) (hidden map[string]bool, readOnly map[string]bool)
+}
+
 // Accessor is implemented by types wishing to register their structs
 // with the admin. It enables the admin to read/write administered objects.
+// New implementations should prefer ContextAccessor.
 type Accessor interface {
 	// Return an single empty instance of the model
 	Prototype() (result interface{})
@@ -82,38 +141,94 @@ type Accessor interface {
 // Seacher provides a list of fields (for admin users to see what's being searched)
 // and a search function that returns a list of results based on the provided url.Values,
 // the count per page, the page number, and any sort order.
+// SearchContext is used in place of Search if set.
 type Searcher struct {
-	Placeholder string // preferred to indicate fields that are being searched
-	Search      func(count, page int, query string, order []Order) (results interface{}, totalCount int, err error)
+	Placeholder   string // preferred to indicate fields that are being searched
+	Search        func(count, page int, query string, order []Order) (results interface{}, totalCount int, err error)
+	SearchContext func(ctx context.Context, count, page int, query string, order []Order) (results interface{}, totalCount int, err error)
 }
 
-// Order provides the information necessary to sort on a field
+// Order provides the information necessary to sort on a field.
+// FieldName is the field's column if the ModelAdmin maps it in FieldColumns.
 type Order struct {
 	FieldName string
 	Ascending bool
 }
 
+// parse a list view sort parameter like "-Created,Name" into orders, highest priority first,
+// ignoring repeated fields
+func parseOrder(sort string) (order []Order) {
+	seen := make(map[string]bool)
+	for _, s := range strings.Split(sort, ",") {
+		field := strings.TrimPrefix(s, "-")
+		if field == "" || seen[field] {
+			continue
+		}
+		seen[field] = true
+		order = append(order, Order{field, !strings.HasPrefix(s, "-")})
+	}
+	return order
+}
+
+// the storage column of a field, as mapped in FieldColumns
+func (m *ModelAdmin) column(field string) string {
+	if column := m.FieldColumns[field]; column != "" {
+		return column
+	}
+	return field
+}
+
+// the orders with their fields mapped to columns
+func (m *ModelAdmin) columnOrder(order []Order) []Order {
+	if len(m.FieldColumns) == 0 {
+		return order
+	}
+	columns := make([]Order, len(order))
+	for i, o := range order {
+		columns[i] = Order{m.column(o.FieldName), o.Ascending}
+	}
+	return columns
+}
+
 // PKStringer converts the primary key to a string if it isn't one
 type PKStringer interface {
 	PKString(pk interface{}) string
 }
 
 // meta information to register the model with the admin
-// note that field order is controlled by the admin, which currently sorts alphabetically by field name
-// which is the default result of iterating a map in a template.
-// TODO: we may want to revisit this approach to ordering.
+// Fields are shown in the order they are declared on the Prototype, unless
+// FieldOrder, ListOrder or Fieldsets say otherwise.
+// The field maps can also be populated from godmin struct tags on the Prototype (see tagName);
+// entries set explicitly on the ModelAdmin override the tags.
 type ModelAdmin struct {
 	ModelName      string // database table/collection name
 	PKFieldName    string
 	ListFields     map[string]bool   // optional fields to be shown in list views. True if sortable, else false
-	OmitFields     map[string]bool   // optional fields to omit from change view
-	ReadOnlyFields map[string]bool   // optional read-only fields for change view
+	OmitFields     map[string]bool   // optional fields to omit from change view (whatever their value)
+	ReadOnlyFields map[string]bool   // optional read-only fields for change view (whatever their value)
 	FieldNotes     map[string]string // optional note about the field
 	FieldWidgets   map[string]string // optional type of widget to render with
+	FieldLabels    map[string]string // optional label to show in place of the field name
+	FieldColumns   map[string]string // optional storage column of a field, given to accessors in Orders and Conditions
+	FieldOrder     []string          // optional order of fields in the change view, unlisted fields follow
+	ListOrder      []string          // optional order of ListFields columns in the list view
+	Fieldsets      []Fieldset        // optional groups of fields in the change view, unlisted fields follow
 	ListActions    map[string]*AdminAction
+	ListFilters    []ListFilter // optional filters shown in the list view's sidebar
+	DefaultOrder   []Order      // the list view's order when the user hasn't chosen one
+	// optionally reject saves of objects changed since their change form was rendered,
+	// showing what changed so the user can merge or overwrite the changes
+	DetectConflicts bool
+	VersionField    string // optional field holding a revision number or update time, compared in place of every field
 	PKStringer
 	Accessor
+	ContextAccessor ContextAccessor // used in place of Accessor if set
 	*Searcher
+	Hooks     interface{}                // optional value implementing object hooks from the hooks package, defaults to the accessor
+	ScopeFunc func(c *gin.Context) Scope // optional restriction of the objects the user can reach, see ScopeFromContext
+	listOrder []string                   // computed list view columns
+	fieldsets []Fieldset                 // computed change view fieldsets
+	viewOnly  bool                       // the requesting user may view but not change the object
 }
 
 // return a new ModelAdmin from the supplied arguments
@@ -121,198 +236,472 @@ func NewModelAdmin(modelName string, pkFieldName string, listFields map[string]b
 	omitFields map[string]bool, readOnlyFields map[string]bool, fieldNotes map[string]string,
 	fieldWidgets map[string]string, pkStringer PKStringer, accessor Accessor, searcher *Searcher) (ma ModelAdmin) {
 	ma = ModelAdmin{
-		modelName,
-		pkFieldName,
-		listFields,
-		omitFields,
-		readOnlyFields,
-		fieldNotes,
-		fieldWidgets,
-		make(map[string]*AdminAction),
-		pkStringer,
-		accessor,
-		searcher,
+		ModelName:      modelName,
+		PKFieldName:    pkFieldName,
+		ListFields:     listFields,
+		OmitFields:     omitFields,
+		ReadOnlyFields: readOnlyFields,
+		FieldNotes:     fieldNotes,
+		FieldWidgets:   fieldWidgets,
+		ListActions:    make(map[string]*AdminAction),
+		PKStringer:     pkStringer,
+		Accessor:       accessor,
+		Searcher:       searcher,
 	}
 	return
 }
 
+// the ContextAccessor if set, otherwise the Accessor adapted to a ContextAccessor
+func (m *ModelAdmin) accessor() ContextAccessor {
+	if m.ContextAccessor != nil {
+		return m.ContextAccessor
+	}
+	return legacyAccessor{m.Accessor}
+}
+
 // registers an AdminAction for use in the list view
 func (m *ModelAdmin) AddListAction(action *AdminAction) {
 	m.ListActions[action.Identifier] = action
 }
 
 var (
-	adminPath     = "/admin"
-	accountIdKey  = "accountId"
-	usernameKey   = "username"
-	modelAdmins   = make(map[string]ModelAdmin)
-	brand         = "Golang Admin"
-	pageSize      = 100
-	showPageCount = 8
+	accountIdKey = "accountId"
+	usernameKey  = "username"
+	defaultAdmin = NewAdmin()
+)
+
+// Logger is the subset of *log.Logger used by the admin to report errors.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// ErrorHandler is called with the request and the error before the admin renders its error page.
+type ErrorHandler func(c *gin.Context, err error)
+
+// Admin is a single admin site with its own registry of ModelAdmins and settings.
+// Several Admins can be mounted side by side on different router groups.
+type Admin struct {
+	adminPath     string
+	brand         string
+	pageSize      int
+	showPageCount int
 	loginURL      string
 	logoutURL     string
 	authenticator Authenticator
-)
+	logger        Logger
+	errorHandler  ErrorHandler
+	csrf          CSRFStore
+	auditLog      AuditLog
+	versions      VersionStore
+	modelAdmins   map[string]ModelAdmin
+}
 
-//set set the Admin path
-func SetAdminPath(p string) {
-	adminPath = p
+// NewAdmin returns a pointer to a new Admin site with the default settings.
+func NewAdmin() *Admin {
+	a := &Admin{
+		adminPath:     "/admin",
+		brand:         "Golang Admin",
+		pageSize:      100,
+		showPageCount: 8,
+		logger:        log.New(os.Stderr, "godmin: ", log.LstdFlags),
+		modelAdmins:   make(map[string]ModelAdmin),
+	}
+	a.csrf = cookieCSRFStore{a}
+	return a
+}
+
+// set the Admin path
+func (a *Admin) SetAdminPath(p string) {
+	a.adminPath = p
+}
+
+// the path the Admin is served from
+func (a *Admin) AdminPath() string {
+	return a.adminPath
 }
 
 // set the Authenticator
-func SetAuthenticator(a Authenticator) {
-	authenticator = a
+func (a *Admin) SetAuthenticator(auth Authenticator) {
+	a.authenticator = auth
+}
+
+// set the Logger that request errors are written to
+func (a *Admin) SetLogger(l Logger) {
+	a.logger = l
+}
+
+// set a function to be called with every error encountered while handling a request,
+// e.g. to report it to an error tracker
+func (a *Admin) SetErrorHandler(h ErrorHandler) {
+	a.errorHandler = h
+}
+
+// set the store used to issue and check CSRF tokens, replacing the default cookie-based one
+func (a *Admin) SetCSRFStore(store CSRFStore) {
+	a.csrf = store
+}
+
+// set the AuditLog that changes made through the admin are recorded in
+func (a *Admin) SetAuditLog(l AuditLog) {
+	a.auditLog = l
+}
+
+// set the VersionStore that objects' previous versions are kept in
+func (a *Admin) SetVersionStore(store VersionStore) {
+	a.versions = store
 }
 
 // set the Brand name to show
-func SetBrand(b string) {
-	brand = b
+func (a *Admin) SetBrand(b string) {
+	a.brand = b
 }
 
 // set the list page size
-func SetPageSize(p int) {
-	pageSize = p
+func (a *Admin) SetPageSize(p int) {
+	a.pageSize = p
 }
 
 // set the URL where a user can log in
-func SetLoginURL(url string) {
-	loginURL = url
+func (a *Admin) SetLoginURL(url string) {
+	a.loginURL = url
 }
 
 // set the URL where a user can log out
-func SetLogoutURL(url string) {
-	logoutURL = url
+func (a *Admin) SetLogoutURL(url string) {
+	a.logoutURL = url
 }
 
 // register a ModelAdmin instance to be available in the admin
-func Register(ma ModelAdmin) {
+func (a *Admin) Register(ma ModelAdmin) {
 	lcModelName := strings.ToLower(ma.ModelName)
-	if _, exists := modelAdmins[lcModelName]; exists {
-		log.Println(ma.ModelName, "Model Admin already registered")
+	if _, exists := a.modelAdmins[lcModelName]; exists {
+		a.logger.Printf("%v Model Admin already registered", ma.ModelName)
 	}
-	if ma.FieldWidgets == nil {
-		ma.FieldWidgets = defaultWidgets(ma)
+	if ma.Accessor != nil || ma.ContextAccessor != nil {
+		applyTags(&ma)
+		applyOrdering(&ma)
 	}
-	modelAdmins[lcModelName] = ma
+	a.modelAdmins[lcModelName] = ma
+}
+
+// set the Admin path of the default Admin
+func SetAdminPath(p string) {
+	defaultAdmin.SetAdminPath(p)
+}
+
+// set the Authenticator of the default Admin
+func SetAuthenticator(auth Authenticator) {
+	defaultAdmin.SetAuthenticator(auth)
+}
+
+// set the Logger of the default Admin
+func SetLogger(l Logger) {
+	defaultAdmin.SetLogger(l)
+}
+
+// set the ErrorHandler of the default Admin
+func SetErrorHandler(h ErrorHandler) {
+	defaultAdmin.SetErrorHandler(h)
+}
+
+// set the CSRF token store of the default Admin
+func SetCSRFStore(store CSRFStore) {
+	defaultAdmin.SetCSRFStore(store)
+}
+
+// set the AuditLog of the default Admin
+func SetAuditLog(l AuditLog) {
+	defaultAdmin.SetAuditLog(l)
+}
+
+// set the VersionStore of the default Admin
+func SetVersionStore(store VersionStore) {
+	defaultAdmin.SetVersionStore(store)
+}
+
+// set the Brand name to show in the default Admin
+func SetBrand(b string) {
+	defaultAdmin.SetBrand(b)
+}
+
+// set the list page size of the default Admin
+func SetPageSize(p int) {
+	defaultAdmin.SetPageSize(p)
 }
 
-func defaultDot(c *gin.Context) map[string]interface{} {
-	dot := gin.H{"brand": brand, "adminPath": adminPath, "loginURL": loginURL, "logoutURL": logoutURL,
-		"admins": modelAdmins}
+// set the URL where a user can log in to the default Admin
+func SetLoginURL(url string) {
+	defaultAdmin.SetLoginURL(url)
+}
+
+// set the URL where a user can log out of the default Admin
+func SetLogoutURL(url string) {
+	defaultAdmin.SetLogoutURL(url)
+}
+
+// register a ModelAdmin instance to be available in the default Admin
+func Register(ma ModelAdmin) {
+	defaultAdmin.Register(ma)
+}
+
+// set up the default Admin's Routes
+func Routes(r *gin.RouterGroup) {
+	defaultAdmin.Routes(r)
+}
+
+func (a *Admin) defaultDot(c *gin.Context) map[string]interface{} {
+	dot := gin.H{"brand": a.brand, "adminPath": a.adminPath, "loginURL": a.loginURL, "logoutURL": a.logoutURL,
+		"admins": a.visibleAdmins(c)}
 	if accountId, exists := c.Get(accountIdKey); exists {
 		dot["accountId"] = accountId
 	}
 	if username, exists := c.Get(usernameKey); exists {
 		dot["username"] = username
 	}
+	dot["flashes"] = a.popFlashes(c)
+	dot["csrfField"] = csrfField
+	dot["csrfToken"] = a.csrf.Token(c)
 	return dot
 }
 
-// set up the admin Routes, and add in the Authenticator middleware if present
-func Routes(r *gin.RouterGroup) {
+// set up the admin Routes, protecting every POST with a CSRF token
+func (a *Admin) Routes(r *gin.RouterGroup) {
+	g := r.Group("", a.csrfProtect)
 	// root level is list of admin models
-	r.Handle("GET", "/", index)
-	r.Handle("GET", "/:model/", list)
-	r.Handle("POST", "/:model/", listUpdate)
-	r.Handle("GET", "/:model/:pk", change)
-	r.Handle("POST", "/:model/:pk", changeUpdate)
+	g.Handle("GET", "/", a.index)
+	g.Handle("GET", "/:model/", a.list)
+	g.Handle("POST", "/:model/", a.listUpdate)
+	g.Handle("GET", "/:model/:pk", a.change)
+	g.Handle("POST", "/:model/:pk", a.changeUpdate)
+	g.Handle("GET", "/:model/:pk/history", a.history)
+	g.Handle("GET", "/:model/:pk/versions", a.versionList)
+	g.Handle("POST", "/:model/:pk/versions", a.revert)
+	if ra, ok := a.authenticator.(RouteAuthenticator); ok {
+		ra.Routes(a, g)
+	}
+}
+
+// Render renders the named admin template with data added to the values every admin page receives,
+// for use by Authenticators and other extensions serving their own admin pages.
+func (a *Admin) Render(c *gin.Context, status int, name string, data gin.H) {
+	dot := a.defaultDot(c)
+	for k, v := range data {
+		dot[k] = v
+	}
+	c.HTML(status, name, dot)
 }
 
 func ParseTemplates(t *template.Template) {
-	fmt.Println("Parsing admin templates")
 	templ.LoadTemplates(t, "index.html",
 		"list.html", "change.html", "bootstrap.html",
 		"navbar.html", "paginator.html", "confirmModal.html",
 		"tableWidgets.html", "formWidgets.html", "error.html",
-		"footer.html")
+		"footer.html", "flashes.html", "login.html",
+		"history.html", "versions.html", "conflict.html",
+		"filters.html")
+}
+
+// look up the ModelAdmin named in the URL, rendering a not found page if there isn't one
+func (a *Admin) lookupModelAdmin(c *gin.Context) (ma ModelAdmin, exists bool) {
+	ma, exists = a.modelAdmins[strings.ToLower(c.Param("model"))]
+	if !exists {
+		a.renderError(c, ErrNotFound)
+	}
+	return
+}
+
+// the ModelAdmins the requesting user may view, for the home page and navbar
+func (a *Admin) visibleAdmins(c *gin.Context) map[string]ModelAdmin {
+	if a.authenticator == nil {
+		return a.modelAdmins
+	}
+	visible := make(map[string]ModelAdmin)
+	for model, admin := range a.modelAdmins {
+		if a.authenticator.HasPrivilege(c, admin.ModelName, PrivilegeView, nil) {
+			visible[model] = admin
+		}
+	}
+	return visible
+}
+
+// the ModelAdmin as the requesting user may see it, with the fields hidden and made read-only
+// by a FieldAuthenticator added to its OmitFields and ReadOnlyFields
+func (a *Admin) restrictFields(c *gin.Context, ma ModelAdmin) ModelAdmin {
+	fa, ok := a.authenticator.(FieldAuthenticator)
+	if !ok {
+		return ma
+	}
+	hidden, readOnly := fa.FieldPermissions(c, ma.ModelName)
+	if len(hidden) == 0 && len(readOnly) == 0 {
+		return ma
+	}
+	ma.OmitFields = copyBoolMap(ma.OmitFields)
+	ma.ReadOnlyFields = copyBoolMap(ma.ReadOnlyFields)
+	for field, ok := range hidden {
+		if ok {
+			ma.OmitFields[field] = true
+		}
+	}
+	for field, ok := range readOnly {
+		if ok {
+			ma.ReadOnlyFields[field] = true
+		}
+	}
+	listOrder := make([]string, 0, len(ma.listOrder))
+	for _, field := range ma.listOrder {
+		if !ma.OmitFields[field] {
+			listOrder = append(listOrder, field)
+		}
+	}
+	ma.listOrder = listOrder
+	return ma
+}
+
+// the ModelAdmin for a user who may view an object but not change it, with every field read-only
+func viewOnly(ma ModelAdmin) ModelAdmin {
+	ma.ReadOnlyFields = copyBoolMap(ma.ReadOnlyFields)
+	for _, fieldset := range ma.fieldsets {
+		for _, field := range fieldset.Fields {
+			ma.ReadOnlyFields[field] = true
+		}
+	}
+	ma.viewOnly = true
+	return ma
+}
+
+// whether the user has the privilege, without rendering an error if not
+func (a *Admin) hasPrivilege(c *gin.Context, collection string, action string, ids []string) bool {
+	return a.authenticator == nil || a.authenticator.HasPrivilege(c, collection, action, ids)
 }
 
 // Check for permission issues via the status code set by the Authenticator
-func hasPermissions(c *gin.Context, collection string, action string, ids []string) (ok bool) {
-	dot := defaultDot(c)
-	if !authenticator.IsAdmin(c) {
-		dot["error"] = "Please log in with an admin account."
-		c.HTML(200, "admin/error.html", dot)
+func (a *Admin) hasPermissions(c *gin.Context, collection string, action string, ids []string) (ok bool) {
+	if a.authenticator == nil {
+		return true
+	}
+	if !a.authenticator.IsAdmin(c) {
+		a.renderError(c, ErrUnauthorized)
 		return false
 	}
-	if !authenticator.HasPrivilege(c, collection, action, ids) {
-		dot["error"] = "You don't have the necessary permissions to do that."
-		c.HTML(200, "admin/error.html", dot)
+	if !a.authenticator.HasPrivilege(c, collection, action, ids) {
+		a.renderError(c, ErrForbidden)
 		return false
 	}
 	return true
 }
 
 // Admin home page
-func index(c *gin.Context) {
-	if !hasPermissions(c, "", "read", nil) {
+func (a *Admin) index(c *gin.Context) {
+	if !a.hasPermissions(c, "", PrivilegeView, nil) {
 		return
 	}
 	var objectCounts = make(map[string]int)
-	for model, admin := range modelAdmins {
-		count, _ := admin.Accessor.Count()
+	for model, admin := range a.visibleAdmins(c) {
+		count, err := admin.accessor().Count(admin.requestContext(c))
+		if err != nil {
+			a.logger.Printf("error counting %v: %v", admin.ModelName, err)
+		}
 		objectCounts[model] = count
 	}
-	dot := defaultDot(c)
+	dot := a.defaultDot(c)
 	dot["counts"] = objectCounts
+	dot["recent"] = a.recentActions(c)
 	c.HTML(200, "admin/index.html", dot)
 }
 
 // list of model instances, with dropdown actions and checkboxes
-func list(c *gin.Context) {
+func (a *Admin) list(c *gin.Context) {
 	var (
-		results interface{}
-		err     error
-		page    int
-		count   int
-		query   string
-		sort    string
-		order   []Order
-		orders  = make(map[string]int)
+		results    interface{}
+		err        error
+		page       int
+		count      int
+		query      string
+		sort       string
+		order      []Order
+		orders     = make(map[string]int)
+		priorities = make(map[string]int)
 	)
-	modelAdmin, exists := modelAdmins[strings.ToLower(c.Param("model"))]
+	modelAdmin, exists := a.lookupModelAdmin(c)
 	if !exists {
-		c.String(http.StatusNotFound, "Not found.")
 		return
 	}
-	if !hasPermissions(c, modelAdmin.ModelName, "read", nil) {
+	if !a.hasPermissions(c, modelAdmin.ModelName, PrivilegeView, nil) {
 		return
 	}
+	modelAdmin = a.restrictFields(c, modelAdmin)
 	page, err = strconv.Atoi(c.DefaultQuery("page", "0"))
+	if err != nil || page < 0 {
+		a.renderListError(c, badRequest(fmt.Errorf("invalid page %q", c.Query("page"))))
+		return
+	}
 	query = c.Query("q")
-	sort = c.Query("o")
+	sort, sorted := c.GetQuery("o")
 	for field, _ := range modelAdmin.ListFields {
 		orders[field] = 0
 	}
-	if sort != "" {
-		field := strings.TrimPrefix(sort, "-")
-		ascend := true
-		if strings.HasPrefix(sort, "-") {
-			ascend = false
+	if sorted {
+		order = parseOrder(sort) // an empty o turns off the default order
+		for _, o := range order {
+			if !modelAdmin.ListFields[o.FieldName] || modelAdmin.OmitFields[o.FieldName] {
+				a.renderListError(c, badRequest(fmt.Errorf("can't sort by %q", o.FieldName)))
+				return
+			}
 		}
-		order = []Order{Order{field, ascend}}
-		if ascend {
-			orders[field] = 1
+	} else {
+		order = modelAdmin.DefaultOrder
+	}
+	sortFields := make([]string, len(order))
+	for i, o := range order {
+		sortFields[i] = o.FieldName
+		if o.Ascending {
+			orders[o.FieldName] = 1
 		} else {
-			orders[field] = -1
+			orders[o.FieldName] = -1
+			sortFields[i] = "-" + o.FieldName
+		}
+		if len(order) > 1 {
+			priorities[o.FieldName] = i + 1
 		}
 	}
 
-	if modelAdmin.Searcher == nil || query == "" {
-		results, err = modelAdmin.Accessor.List(pageSize, page, order)
-		count, _ = modelAdmin.Accessor.Count()
+	conditions, err := modelAdmin.parseFilters(c.Request.URL.Query())
+	if err != nil {
+		a.renderListError(c, badRequest(err))
+		return
+	}
+	if len(conditions) > 0 && !modelAdmin.canFilter(query) {
+		a.renderListError(c, badRequest(fmt.Errorf("the %v list can't be filtered", modelAdmin.ModelName)))
+		return
+	}
+	order, conditions = modelAdmin.columnOrder(order), modelAdmin.columnConditions(conditions)
+	ctx := modelAdmin.requestContext(c)
+	if len(conditions) > 0 {
+		ctx = context.WithValue(ctx, filtersContextKey{}, conditions)
+	}
+	if fa := modelAdmin.filterAccessor(); fa != nil && (modelAdmin.Searcher == nil || query == "") {
+		results, err = fa.ListFiltered(ctx, a.pageSize, page, order, conditions)
+		if err == nil {
+			count, err = fa.CountFiltered(ctx, conditions)
+		}
+	} else if modelAdmin.Searcher == nil || query == "" {
+		results, err = modelAdmin.accessor().List(ctx, a.pageSize, page, order)
+		if err == nil {
+			count, err = modelAdmin.accessor().Count(ctx)
+		}
 	} else {
-		results, count, err = modelAdmin.Searcher.Search(pageSize, page, query, order)
+		results, count, err = modelAdmin.Searcher.search(ctx, a.pageSize, page, query, order)
 	}
 	if err != nil {
-		log.Fatal("error in godmin list:", err)
+		a.renderError(c, err)
+		return
 	}
 
-	totalPages := count / pageSize
-	if remainder := math.Mod(float64(count), float64(pageSize)); remainder > 0.0 {
+	totalPages := count / a.pageSize
+	if remainder := math.Mod(float64(count), float64(a.pageSize)); remainder > 0.0 {
 		totalPages += 1
 	}
-	numPages := int(math.Min(float64(showPageCount), float64(totalPages)))
+	numPages := int(math.Min(float64(a.showPageCount), float64(totalPages)))
 	pages := make([]int, numPages)
 	startPage := int(math.Max(0, float64(page-(numPages/2))))
 	endPage := int(math.Min(float64(totalPages-1), float64(startPage+numPages-1)))
@@ -322,16 +711,27 @@ func list(c *gin.Context) {
 	}
 
 	resultValues := reflect.ValueOf(results)
+	if resultValues.Kind() != reflect.Slice {
+		a.renderError(c, fmt.Errorf("godmin: the %v accessor listed a %T rather than a slice", modelAdmin.ModelName, results))
+		return
+	}
 	resultCount := resultValues.Len()
-	mapResults := make([][]AdminField, resultCount, resultCount)
-	pks := make([]string, resultCount, resultCount)
+	mapResults := make([][]AdminField, 0, resultCount)
+	pks := make([]string, 0, resultCount)
+	scope := ScopeFromContext(ctx)
 	for i := 0; i < resultCount; i++ {
-		mapResults[i] = Marshal(resultValues.Index(i).Interface(), modelAdmin, "")
-		pks[i] = modelAdmin.PKStringer.PKString(resultValues.Index(i).FieldByName(modelAdmin.PKFieldName).Interface())
+		result := resultValues.Index(i).Interface()
+		if !scope.contains(result) {
+			continue // the accessor should have left it out
+		}
+		result = modelAdmin.load(ctx, result)
+		mapResults = append(mapResults, Marshal(result, modelAdmin, ""))
+		pks = append(pks, modelAdmin.PKStringer.PKString(reflect.Indirect(reflect.ValueOf(result)).FieldByName(modelAdmin.PKFieldName).Interface()))
 	}
-	dot := defaultDot(c)
+	dot := a.defaultDot(c)
 	dot["modelAdmin"] = modelAdmin
 	dot["results"] = mapResults
+	dot["listFields"] = modelAdmin.listOrder
 	dot["pks"] = pks
 	dot["page"] = page
 	dot["pages"] = pages
@@ -339,6 +739,18 @@ func list(c *gin.Context) {
 	dot["query"] = query
 	dot["orders"] = orders
 	dot["sort"] = sort
+	dot["sorted"] = sorted
+	dot["sortFields"] = sortFields
+	dot["priorities"] = priorities
+	if modelAdmin.canFilter(query) {
+		dot["filters"] = modelAdmin.filterViews(c.Request.URL.Query())
+		// page links keep the filters
+		dot["filterParams"] = template.URL(filterParams(c.Request.URL.Query()).Encode())
+	}
+	if modelAdmin.Searcher != nil && modelAdmin.Searcher.SearchContext != nil {
+		// searches keep the filters, which only a SearchContext can apply
+		dot["filterQuery"] = template.URL(filterParams(c.Request.URL.Query()).Encode())
+	}
 	if modelAdmin.Searcher != nil {
 		dot["search"] = true
 		dot["searchPlaceholder"] = modelAdmin.Searcher.Placeholder
@@ -347,141 +759,293 @@ func list(c *gin.Context) {
 }
 
 // handle actions to be executed on a set of objects from a model's list view
-func listUpdate(c *gin.Context) {
-	modelAdmin, exists := modelAdmins[strings.ToLower(c.Param("model"))]
+func (a *Admin) listUpdate(c *gin.Context) {
+	modelAdmin, exists := a.lookupModelAdmin(c)
 	if !exists {
-		c.String(http.StatusNotFound, "Not found.")
-		return
-	}
-	if !hasPermissions(c, modelAdmin.ModelName, "write", nil) { // TODO: add in the IDs
 		return
 	}
 	err := c.Request.ParseForm()
 	if err != nil {
-		log.Fatal(err)
+		a.renderError(c, badRequest(err))
+		return
 	}
+	ids := c.Request.Form["ids"]
 	action := c.PostForm("action")
-	if listAction, exists := modelAdmin.ListActions[action]; exists {
+	listAction, exists := modelAdmin.ListActions[action]
+	privilege := PrivilegeView
+	if exists {
+		privilege = ActionPrivilege(listAction.Identifier)
+	}
+	if !a.hasPermissions(c, modelAdmin.ModelName, privilege, ids) {
+		return
+	}
+	ctx := modelAdmin.requestContext(c)
+	if modelAdmin.ScopeFunc != nil {
+		// only act on objects within the user's scope
+		for _, id := range ids {
+			if _, err := modelAdmin.get(ctx, id); err != nil {
+				a.renderError(c, err)
+				return
+			}
+		}
+	}
+	if exists {
 		form := c.Request.Form
-		listAction.Action(&form)
+		message, err := listAction.run(ctx, &form)
+		if err != nil {
+			_, reason := a.reportError(c, err)
+			a.AddFlash(c, FlashError, fmt.Sprintf("%v failed: %v", listAction.DisplayName, reason))
+		} else {
+			if message == "" {
+				message = fmt.Sprintf("%v done.", listAction.DisplayName)
+			}
+			a.AddFlash(c, FlashSuccess, message)
+			for _, id := range ids {
+				a.audit(ctx, modelAdmin, id, listAction.Identifier, nil, nil)
+			}
+		}
+	} else {
+		a.AddFlash(c, FlashWarning, "Please select an action.")
 	}
-	list(c)
+	c.Redirect(http.StatusFound, c.Request.URL.RequestURI())
 }
 
 // change form for model, with actions as buttons
-func change(c *gin.Context) {
-	modelAdmin, exists := modelAdmins[strings.ToLower(c.Param("model"))]
+func (a *Admin) change(c *gin.Context) {
+	modelAdmin, exists := a.lookupModelAdmin(c)
 	if !exists {
-		c.String(http.StatusNotFound, "Not found.")
 		return
 	}
 	pk := c.Param("pk")
 	if pk == "add" {
-		if hasPermissions(c, modelAdmin.ModelName, "create", nil) {
-			create(c)
+		if a.hasPermissions(c, modelAdmin.ModelName, PrivilegeAdd, nil) {
+			a.create(c)
 		}
 		return
 	}
-	if !hasPermissions(c, modelAdmin.ModelName, "write", []string{pk}) {
+	if !a.hasPermissions(c, modelAdmin.ModelName, PrivilegeView, []string{pk}) {
 		return
 	}
-	result, err := modelAdmin.Accessor.Get(pk)
+	modelAdmin = a.restrictFields(c, modelAdmin)
+	if !a.hasPrivilege(c, modelAdmin.ModelName, PrivilegeChange, []string{pk}) {
+		modelAdmin = viewOnly(modelAdmin)
+	}
+	ctx := modelAdmin.requestContext(c)
+	result, err := modelAdmin.get(ctx, pk)
 	if err != nil {
-		if err.Error() == "Not Found" {
-			c.String(http.StatusNotFound, "Not found.")
-			return
-		}
-		if err.Error() == "Invalid ID" {
-			c.String(http.StatusNotFound, "Invalid ID.")
-			return
-		}
-		log.Fatal(err)
+		a.renderError(c, err)
+		return
 	}
-	dot := defaultDot(c)
+	values := ValuesMapper(modelAdmin.load(ctx, result))
+	a.renderChange(c, modelAdmin, pk, values, values, nil)
+}
+
+// render the change form for pk ("add" for a new object) with the given field values,
+// showing the error next to the offending fields if it's a ValidationError.
+// stored are the object's values before any changes submitted by the user.
+func (a *Admin) renderChange(c *gin.Context, modelAdmin ModelAdmin, pk string, values map[string]string, stored map[string]string, err error) {
+	status := http.StatusOK
+	dot := a.defaultDot(c)
 	dot["modelAdmin"] = modelAdmin
-	dot["values"] = ValuesMapper(result)
+	if modelAdmin.DetectConflicts && pk != "add" {
+		dot["version"] = modelAdmin.versionOf(stored)
+		dot["original"] = encodeValues(visibleValues(modelAdmin, stored))
+	}
+	for field := range modelAdmin.OmitFields {
+		delete(values, field)
+	}
+	dot["values"] = values
+	dot["fieldsets"] = modelAdmin.fieldsets
 	dot["pk"] = pk
-	c.HTML(200, "admin/change.html", dot)
+	dot["versioned"] = a.versions != nil
+	dot["viewOnly"] = modelAdmin.viewOnly
+	dot["canDelete"] = pk != "add" && a.hasPrivilege(c, modelAdmin.ModelName, PrivilegeDelete, []string{pk})
+	dot["fieldErrors"] = map[string]string{}
+	if err != nil {
+		status, dot["formError"] = a.reportError(c, err)
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			dot["formError"] = "Please correct the errors below."
+			fieldErrors := make(map[string]string)
+			for field, msg := range verr.Fields {
+				// show errors on nested values next to their top level field
+				if i := strings.Index(field, "."); i >= 0 {
+					msg = field[i+1:] + ": " + msg
+					field = field[:i]
+				}
+				fieldErrors[field] = msg
+			}
+			dot["fieldErrors"] = fieldErrors
+		}
+	}
+	c.HTML(status, "admin/change.html", dot)
 }
 
-// upsert an object from HTML form values
-func saveFromForm(c *gin.Context) {
-	log.Println("hitting SaveFromForm")
-	modelAdmin, exists := modelAdmins[strings.ToLower(c.Param("model"))]
+// upsert an object from HTML form values after decoding and validating them,
+// re-rendering the change form with the submitted values and returning false on failure
+func (a *Admin) saveFromForm(c *gin.Context) (ok bool) {
+	modelAdmin, exists := a.lookupModelAdmin(c)
 	if !exists {
-		c.String(http.StatusNotFound, "Not found.")
-		return
+		return false
 	}
+	modelAdmin = a.restrictFields(c, modelAdmin)
 	pk := c.Param("pk")
 	if pk == "add" {
 		pk = ""
 	}
 	err := c.Request.ParseForm()
 	if err != nil {
-		log.Fatal(err)
+		a.renderError(c, badRequest(err))
+		return false
 	}
 	form := c.Request.Form
-	log.Println("form", form)
+	version, original := form.Get(versionField), form.Get(originalField)
+	delete(form, versionField)
+	delete(form, originalField)
 	objectMap := Unmarshal(form, &modelAdmin)
-	log.Println(objectMap)
-	// proto := modelAdmin.Accessor.Prototype()
-	if len(objectMap) > 0 {
-		_, err = modelAdmin.Accessor.Upsert(pk, objectMap)
+	ctx := modelAdmin.requestContext(c)
+	ScopeFromContext(ctx).apply(objectMap)
+	// decode onto the stored object so fields missing from the form keep their values
+	base := modelAdmin.accessor().Prototype()
+	if pk != "" {
+		if base, err = modelAdmin.get(ctx, pk); err != nil {
+			a.renderError(c, err)
+			return false
+		}
 	}
-	if err != nil {
-		log.Println(err)
-		if err.Error() == "Not Found" {
-			c.String(http.StatusNotFound, "Not found.")
-			return
+	base = modelAdmin.load(ctx, base)
+	stored := ValuesMapper(base)
+	if modelAdmin.DetectConflicts && pk != "" && version != "" && version != modelAdmin.versionOf(stored) {
+		submitted := make(map[string]string, len(objectMap))
+		for field, vals := range objectMap {
+			if len(vals) > 0 {
+				submitted[field] = vals[0]
+			}
 		}
-		if err.Error() == "Invalid ID" {
-			c.String(http.StatusNotFound, "Invalid ID.")
-			return
+		a.renderConflict(c, modelAdmin, pk, decodeValues(original), stored, submitted)
+		return false
+	}
+	obj, err := Decode(objectMap, base)
+	if err == nil {
+		err = Validate(obj)
+	} else if validateErr := Validate(obj); validateErr != nil {
+		// report conversion and validation problems together, conversion taking precedence
+		var decodeErr *ValidationError
+		if errors.As(err, &decodeErr) {
+			for field, msg := range validateErr.(*ValidationError).Fields {
+				if _, exists := decodeErr.Fields[field]; !exists {
+					decodeErr.Fields[field] = msg
+				}
+			}
 		}
-		c.String(http.StatusNotAcceptable, err.Error())
-		return
 	}
+	if err == nil {
+		decoded := ValuesMapper(obj)
+		obj, err = modelAdmin.preSave(ctx, pk, obj)
+		if err == nil && modelAdmin.saver() == nil {
+			hookedValues(objectMap, decoded, ValuesMapper(obj))
+		}
+	}
+	if err == nil {
+		outPk := pk
+		if saver := modelAdmin.saver(); saver != nil {
+			outPk, err = saver.Save(ctx, pk, obj)
+		} else if len(objectMap) > 0 {
+			outPk, err = modelAdmin.accessor().Upsert(ctx, pk, objectMap)
+		}
+		if err == nil {
+			modelAdmin.postSave(ctx, outPk, obj, pk == "")
+			if pk == "" {
+				a.audit(ctx, modelAdmin, outPk, AuditCreate, nil, ValuesMapper(obj))
+			} else {
+				a.addVersion(ctx, modelAdmin, pk, stored)
+				a.audit(ctx, modelAdmin, outPk, AuditChange, stored, ValuesMapper(obj))
+			}
+		}
+	}
+	if err != nil {
+		values := ValuesMapper(base)
+		for field := range values {
+			if submitted, exists := objectMap[field]; exists && len(submitted) > 0 {
+				values[field] = submitted[0]
+			}
+		}
+		a.renderChange(c, modelAdmin, c.Param("pk"), values, stored, err)
+		return false
+	}
+	return true
 }
 
 // update an object from its change form
-func changeUpdate(c *gin.Context) {
-	log.Println("hitting changeUpdate")
+func (a *Admin) changeUpdate(c *gin.Context) {
 	action := c.DefaultPostForm("action", "save")
 	delete(c.Request.Form, "action") // don't keep this as part of the object
-	modelAdmin, exists := modelAdmins[strings.ToLower(c.Param("model"))]
+	modelAdmin, exists := a.lookupModelAdmin(c)
 	if !exists {
-		c.String(http.StatusNotFound, "Not found.")
 		return
 	}
-	if !hasPermissions(c, modelAdmin.ModelName, "write", nil) { // TODO: add in the ID(s)
+	pk := c.Param("pk")
+	privilege := PrivilegeChange
+	switch {
+	case action == "delete":
+		privilege = PrivilegeDelete
+	case pk == "add":
+		privilege = PrivilegeAdd
+	}
+	var ids []string
+	if pk != "add" {
+		ids = []string{pk}
+	}
+	if !a.hasPermissions(c, modelAdmin.ModelName, privilege, ids) {
 		return
 	}
+	modelAdmin = a.restrictFields(c, modelAdmin)
 	switch action {
 	case "save":
-		saveFromForm(c)
+		if !a.saveFromForm(c) {
+			return
+		}
+		a.AddFlash(c, FlashSuccess, fmt.Sprintf("The %v was saved.", modelAdmin.ModelName))
 		c.Request.Method = "GET"
 		c.Redirect(http.StatusFound, fmt.Sprintf("../%v", strings.ToLower(c.Param("model"))))
 	case "save-continue":
-		saveFromForm(c)
-		change(c)
+		if !a.saveFromForm(c) {
+			return
+		}
+		a.AddFlash(c, FlashSuccess, fmt.Sprintf("The %v was saved.", modelAdmin.ModelName))
+		a.change(c)
 	case "delete":
-		modelAdmin.Accessor.DeletePK(c.Param("pk"))
+		ctx := modelAdmin.requestContext(c)
+		obj, err := modelAdmin.get(ctx, pk)
+		if err != nil {
+			a.renderError(c, err)
+			return
+		}
+		obj = modelAdmin.load(ctx, obj)
+		if err := modelAdmin.preDelete(ctx, pk, obj); err != nil {
+			a.renderChange(c, modelAdmin, pk, ValuesMapper(obj), ValuesMapper(obj), err)
+			return
+		}
+		if err := modelAdmin.accessor().DeletePK(ctx, pk); err != nil {
+			a.renderError(c, err)
+			return
+		}
+		modelAdmin.postDelete(ctx, pk, obj)
+		a.addVersion(ctx, modelAdmin, pk, ValuesMapper(obj))
+		a.audit(ctx, modelAdmin, pk, AuditDelete, ValuesMapper(obj), nil)
+		a.AddFlash(c, FlashSuccess, fmt.Sprintf("The %v %v was deleted.", modelAdmin.ModelName, pk))
 		c.Request.Method = "GET"
 		c.Redirect(http.StatusFound, fmt.Sprintf("../%v", strings.ToLower(c.Param("model"))))
 	}
 }
 
 // create form for model, with actions as buttons
-func create(c *gin.Context) {
-	modelAdmin, exists := modelAdmins[strings.ToLower(c.Param("model"))]
+func (a *Admin) create(c *gin.Context) {
+	modelAdmin, exists := a.lookupModelAdmin(c)
 	if !exists {
-		c.String(http.StatusNotFound, "Not found.")
 		return
 	}
-	result := modelAdmin.Accessor.Prototype()
-	dot := defaultDot(c)
-	dot["modelAdmin"] = modelAdmin
-	dot["pk"] = "add"
-	dot["values"] = ValuesMapper(result)
-	c.HTML(200, "admin/change.html", dot)
+	modelAdmin = a.restrictFields(c, modelAdmin)
+	result := modelAdmin.load(modelAdmin.requestContext(c), modelAdmin.accessor().Prototype())
+	a.renderChange(c, modelAdmin, "add", ValuesMapper(result), nil, nil)
 }
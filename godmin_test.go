@@ -1,9 +1,22 @@
 package godmin
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
 	// "reflect"
 	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
 type TestObject struct {
@@ -14,7 +27,7 @@ type TestObject struct {
 }
 
 func TestWwwForm(t *testing.T) {
-	admin := NewModelAdmin("test", "test", nil, nil, nil, nil, nil, nil, nil)
+	admin := NewModelAdmin("test", "test", nil, nil, nil, nil, nil, nil, nil, nil)
 	fmt.Println("testing www form Marshal")
 	location := "Vancouver"
 	obj := TestObject{"Obj", &location, nil, nil}
@@ -22,3 +35,1366 @@ func TestWwwForm(t *testing.T) {
 	marshaled := Marshal(obj2, admin, "")
 	fmt.Println("marshaled", marshaled)
 }
+
+func TestAdminsAreIndependent(t *testing.T) {
+	ops := NewAdmin()
+	support := NewAdmin()
+	ops.SetBrand("Ops")
+	ops.SetPageSize(10)
+	ops.Register(NewModelAdmin("test", "Name", nil, nil, nil, nil, map[string]string{}, nil, nil, nil))
+	if support.brand == ops.brand || support.pageSize == ops.pageSize {
+		t.Error("settings leaked between admins")
+	}
+	if _, exists := support.modelAdmins["test"]; exists {
+		t.Error("registry leaked between admins")
+	}
+	if _, exists := defaultAdmin.modelAdmins["test"]; exists {
+		t.Error("registry leaked into the default admin")
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{ErrNotFound, http.StatusNotFound},
+		{fmt.Errorf("user 12: %w", ErrNotFound), http.StatusNotFound},
+		{errors.New("Not Found"), http.StatusNotFound},
+		{errors.New("Invalid ID"), http.StatusNotFound},
+		{fmt.Errorf("bad hex: %w", ErrInvalidPK), http.StatusNotFound},
+		{ErrConflict, http.StatusConflict},
+		{&ValidationError{map[string]string{"Name": "is required"}}, http.StatusUnprocessableEntity},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		if status, _ := errorStatus(tc.err); status != tc.status {
+			t.Errorf("errorStatus(%v) = %d, want %d", tc.err, status, tc.status)
+		}
+	}
+	var verr *ValidationError
+	if err := fmt.Errorf("save: %w", &ValidationError{}); !errors.As(err, &verr) || !errors.Is(err, ErrValidation) {
+		t.Error("wrapped ValidationError not matched")
+	}
+}
+
+type failingAccessor struct{ err error }
+
+func (f failingAccessor) Prototype() interface{}  { return TestObject{} }
+func (f failingAccessor) PrototypePtr() *struct{} { return nil }
+func (f failingAccessor) Get(pk string) (interface{}, error) {
+	return nil, f.err
+}
+func (f failingAccessor) List(count, page int, order []Order) (interface{}, error) {
+	return nil, f.err
+}
+func (f failingAccessor) Count() (int, error) { return 0, f.err }
+func (f failingAccessor) Upsert(pk string, values map[string][]string) (string, error) {
+	return "", f.err
+}
+func (f failingAccessor) DeletePK(pk string) error { return f.err }
+
+func TestHandlerErrorsDoNotExit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	admin := NewAdmin()
+	var handled []error
+	admin.SetErrorHandler(func(c *gin.Context, err error) {
+		handled = append(handled, err)
+	})
+	admin.SetLogger(log.New(io.Discard, "", 0))
+	admin.Register(NewModelAdmin("test", "Name", nil, nil, nil, nil, nil, nil,
+		failingAccessor{errors.New("query timed out")}, nil))
+	r := testRouter(admin)
+
+	for path, status := range map[string]int{
+		"/admin/test/":  http.StatusInternalServerError,
+		"/admin/test/1": http.StatusInternalServerError,
+		"/admin/nope/":  http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != status {
+			t.Errorf("GET %v: got status %d, want %d", path, w.Code, status)
+		}
+	}
+	if len(handled) != 3 {
+		t.Errorf("error handler called %d times, want 3", len(handled))
+	}
+}
+
+// a router with stand-in templates for the admin pages
+func testRouter(admin *Admin) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	t := template.New("")
+	for _, name := range []string{"index.html", "list.html", "change.html"} {
+		template.Must(t.New("admin/" + name).Parse(name))
+	}
+	template.Must(t.New("admin/error.html").Parse("{{.error}}"))
+	r := gin.New()
+	r.SetHTMLTemplate(t)
+	admin.Routes(r.Group("/admin"))
+	return r
+}
+
+type userRecordingAccessor struct {
+	failingAccessor
+	username string
+}
+
+func (u *userRecordingAccessor) Get(ctx context.Context, pk string) (interface{}, error) {
+	u.username = Username(ctx)
+	return TestObject{Name: pk}, nil
+}
+func (u *userRecordingAccessor) List(ctx context.Context, count, page int, order []Order) (interface{}, error) {
+	return nil, nil
+}
+func (u *userRecordingAccessor) Count(ctx context.Context) (int, error) { return 0, nil }
+func (u *userRecordingAccessor) Upsert(ctx context.Context, pk string, values map[string][]string) (string, error) {
+	return pk, nil
+}
+func (u *userRecordingAccessor) DeletePK(ctx context.Context, pk string) error { return nil }
+
+type fixedUserAuthenticator string
+
+func (f fixedUserAuthenticator) IsAdmin(c *gin.Context) bool {
+	c.Set(usernameKey, string(f))
+	return true
+}
+func (f fixedUserAuthenticator) HasPrivilege(c *gin.Context, collection string, action string, ids []string) bool {
+	return true
+}
+
+func TestContextAccessorReceivesUser(t *testing.T) {
+	admin := NewAdmin()
+	admin.SetAuthenticator(fixedUserAuthenticator("alice"))
+	accessor := &userRecordingAccessor{}
+	ma := NewModelAdmin("test", "Name", nil, nil, nil, nil, nil, nil, nil, nil)
+	ma.ContextAccessor = accessor
+	admin.Register(ma)
+	w := httptest.NewRecorder()
+	testRouter(admin).ServeHTTP(w, httptest.NewRequest("GET", "/admin/test/1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d", w.Code)
+	}
+	if accessor.username != "alice" {
+		t.Errorf("accessor saw username %q, want alice", accessor.username)
+	}
+}
+
+type TaggedObject struct {
+	Name    string `godmin:"list,sortable,label=Full Name,note='Shown to customers, keep it short'"`
+	Email   string `godmin:"list,readonly,column=email_address"`
+	Bio     string `godmin:"widget=textarea"`
+	Hash    string `godmin:"-"`
+	Private bool
+}
+
+type taggedAccessor struct{ failingAccessor }
+
+func (taggedAccessor) Prototype() interface{} { return TaggedObject{} }
+
+func TestStructTags(t *testing.T) {
+	tag := parseTag("list, widget=textarea,note='a, b',label=Full Name")
+	if !tag.has("list") || tag["widget"] != "textarea" || tag["note"] != "a, b" || tag["label"] != "Full Name" {
+		t.Errorf("parseTag got %v", tag)
+	}
+
+	admin := NewAdmin()
+	admin.SetLogger(log.New(io.Discard, "", 0))
+	admin.Register(NewModelAdmin("tagged", "Name", nil, nil, nil, nil, nil, nil, taggedAccessor{}, nil))
+	ma := admin.modelAdmins["tagged"]
+	if sortable, listed := ma.ListFields["Name"]; !listed || !sortable {
+		t.Error("Name should be a sortable list field")
+	}
+	if sortable, listed := ma.ListFields["Email"]; !listed || sortable {
+		t.Error("Email should be an unsortable list field")
+	}
+	if !ma.ReadOnlyFields["Email"] || !ma.OmitFields["Hash"] || ma.FieldWidgets["Bio"] != "textarea" ||
+		ma.FieldWidgets["Private"] != "radio" || ma.FieldLabels["Name"] != "Full Name" ||
+		ma.FieldNotes["Name"] != "Shown to customers, keep it short" || ma.FieldColumns["Email"] != "email_address" {
+		t.Errorf("tags not applied: %+v", ma)
+	}
+
+	// explicit maps override the tags
+	admin.Register(NewModelAdmin("tagged", "Name", map[string]bool{"Bio": false},
+		nil, map[string]bool{"Email": false}, nil, map[string]string{"Bio": "text"}, nil, taggedAccessor{}, nil))
+	ma = admin.modelAdmins["tagged"]
+	if _, listed := ma.ListFields["Name"]; listed || len(ma.ListFields) != 1 {
+		t.Errorf("explicit ListFields should replace tags, got %v", ma.ListFields)
+	}
+	if ma.FieldWidgets["Bio"] != "text" {
+		t.Errorf("explicit maps should override tags: %+v", ma)
+	}
+	// but a field listed in ReadOnlyFields or OmitFields stays read-only or omitted whatever its value
+	if !ma.ReadOnlyFields["Email"] {
+		t.Error("Email should still be read-only")
+	}
+	form := url.Values{"Email": {"x"}, "Bio": {"y"}}
+	if fields := Unmarshal(form, &ModelAdmin{OmitFields: map[string]bool{"Bio": false}, ReadOnlyFields: map[string]bool{"Email": false}}); len(fields) != 0 {
+		t.Errorf("Unmarshal accepted %v", fields)
+	}
+}
+
+type pointerAccessor struct{ failingAccessor }
+
+func (pointerAccessor) Prototype() interface{} { return &HookedObject{} }
+
+func TestPointerPrototype(t *testing.T) {
+	admin := NewAdmin()
+	admin.Register(NewModelAdmin("pointer", "Name", nil, nil, nil, nil, nil, nil, pointerAccessor{}, nil))
+	if widget := admin.modelAdmins["pointer"].FieldWidgets["Name"]; widget != "text" {
+		t.Errorf("got widget %q", widget)
+	}
+}
+
+func TestFieldOrdering(t *testing.T) {
+	ma := NewModelAdmin("tagged", "Name", nil, nil, nil, nil, nil, nil, taggedAccessor{}, nil)
+	ma.FieldOrder = []string{"Bio", "Missing", "Email"}
+	ma.ListOrder = []string{"Email"}
+	ma.Fieldsets = []Fieldset{{Title: "Profile", Fields: []string{"Email", "Bio"}, Collapsed: true}}
+	applyTags(&ma)
+	applyOrdering(&ma)
+	if got := fmt.Sprint(ma.listOrder); got != "[Email Name]" {
+		t.Errorf("list order %v", got)
+	}
+	if len(ma.fieldsets) != 2 {
+		t.Fatalf("expected a trailing fieldset for unplaced fields, got %+v", ma.fieldsets)
+	}
+	if got := fmt.Sprint(ma.fieldsets[0].Fields, ma.fieldsets[1].Fields); got != "[Email Bio] [Name Hash Private]" {
+		t.Errorf("fieldsets %v", got)
+	}
+
+	ma.Fieldsets = nil
+	applyOrdering(&ma)
+	if got := fmt.Sprint(ma.fieldsets[0].Fields); got != "[Bio Email Name Hash Private]" {
+		t.Errorf("field order %v", got)
+	}
+}
+
+type DecodeObject struct {
+	Name    string
+	Age     int
+	Ratio   float64
+	Active  bool
+	Nick    *string
+	Count   *uint8
+	Tags    []string
+	Meta    map[string]string
+	Sub     *TestObject
+	Subs    []*TestObject
+	Created time.Time
+	hidden  string
+}
+
+func TestDecode(t *testing.T) {
+	location := "Vancouver"
+	base := DecodeObject{Name: "old", Sub: &TestObject{Name: "sub", Location: &location},
+		Subs: []*TestObject{{Name: "first"}}, hidden: "kept"}
+	values := map[string][]string{
+		"Age":          {"42"},
+		"Ratio":        {"0.5"},
+		"Active":       {"true"},
+		"Nick":         {"nick"},
+		"Count":        {""},
+		"Tags":         {"a", "b"},
+		"Meta":         {`{"k": "v"}`},
+		"Sub.Name":     {"changed"},
+		"Subs.1.Name":  {"second"},
+		"Created":      {"2020-01-02T03:04:05Z"},
+		"hidden":       {"injected"},
+		"NotAField":    {"ignored"},
+		"Sub.Location": {"Toronto"},
+	}
+	decoded, err := Decode(values, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := decoded.(*DecodeObject)
+	if obj.Name != "old" || obj.Age != 42 || obj.Ratio != 0.5 || !obj.Active || *obj.Nick != "nick" ||
+		obj.Count != nil || fmt.Sprint(obj.Tags) != "[a b]" || obj.Meta["k"] != "v" || obj.hidden != "kept" ||
+		obj.Created.Year() != 2020 {
+		t.Errorf("decoded %+v", obj)
+	}
+	if obj.Sub.Name != "changed" || *obj.Sub.Location != "Toronto" || len(obj.Subs) != 2 ||
+		obj.Subs[0].Name != "first" || obj.Subs[1].Name != "second" {
+		t.Errorf("nested values not decoded: %+v %+v", obj.Sub, obj.Subs)
+	}
+	if base.Sub.Name != "sub" || location != "Vancouver" || len(base.Subs) != 1 {
+		t.Error("decoding modified the base object")
+	}
+
+	_, err = Decode(map[string][]string{"Age": {"old"}, "Active": {"maybe"}, "Subs.x.Name": {"a"}}, base)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Fields) != 3 {
+		t.Errorf("expected three field errors, got %v", err)
+	}
+
+	// slices grow by at most one element per index, in index order
+	values = map[string][]string{}
+	for i := 1; i <= 11; i++ {
+		values[fmt.Sprintf("Subs.%d.Name", i)] = []string{fmt.Sprint(i)}
+	}
+	decoded, err = Decode(values, base)
+	if subs := decoded.(*DecodeObject).Subs; err != nil || len(subs) != 12 || subs[11].Name != "11" {
+		t.Errorf("appending got %v, %v", len(subs), err)
+	}
+	_, err = Decode(map[string][]string{"Subs.99999999999.Name": {"a"}, "Subs.3.Name": {"b"}}, base)
+	if !errors.As(err, &verr) || len(verr.Fields) != 2 {
+		t.Errorf("expected out of range indexes to fail, got %v", err)
+	}
+}
+
+type ValidatedObject struct {
+	Name  string   `godmin:"required,max=5"`
+	Email string   `godmin:"email"`
+	Code  string   `godmin:"regex='^[a-z]{2,3}$'"`
+	Age   int      `godmin:"min=18"`
+	Tags  []string `godmin:"min=1"`
+	Score *int     `godmin:"max=10"`
+}
+
+func (v ValidatedObject) Validate() map[string]string {
+	if v.Name == "admin" {
+		return map[string]string{"Name": "reserved"}
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	score := 11
+	err := Validate(&ValidatedObject{Name: "toolong", Email: "nope", Code: "abcd", Age: 17, Score: &score})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	for _, field := range []string{"Name", "Email", "Code", "Age", "Tags", "Score"} {
+		if verr.Fields[field] == "" {
+			t.Errorf("expected an error for %v", field)
+		}
+	}
+	if err := Validate(&ValidatedObject{Name: "ok", Email: "a@b.com", Code: "ab", Age: 18, Tags: []string{"x"}}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := Validate(ValidatedObject{Name: "admin", Age: 20, Tags: []string{"x"}}); err == nil ||
+		err.(*ValidationError).Fields["Name"] != "reserved" {
+		t.Errorf("Validate method not called, got %v", err)
+	}
+}
+
+type HookedObject struct {
+	Name string
+}
+
+var hookCalls []string
+
+func (h *HookedObject) OnLoad(ctx context.Context) { hookCalls = append(hookCalls, "load") }
+func (h *HookedObject) PreSaveContext(ctx context.Context) error {
+	if h.Name == "veto" {
+		return errors.New("name can't be veto")
+	}
+	h.Name = strings.ToUpper(h.Name)
+	return nil
+}
+func (h *HookedObject) PostCreate(ctx context.Context) { hookCalls = append(hookCalls, "create") }
+func (h *HookedObject) PreDelete(ctx context.Context) error {
+	return errors.New("undeletable")
+}
+
+type hookedAccessor struct {
+	failingAccessor
+	saved []string
+}
+
+func (h *hookedAccessor) Prototype() interface{}             { return HookedObject{} }
+func (h *hookedAccessor) Get(pk string) (interface{}, error) { return HookedObject{Name: pk}, nil }
+func (h *hookedAccessor) Save(pk string, obj interface{}) (string, error) {
+	h.saved = append(h.saved, obj.(*HookedObject).Name)
+	return "new", nil
+}
+func (h *hookedAccessor) PostSaveObject(ctx context.Context, pk string, obj interface{}) {
+	hookCalls = append(hookCalls, "saved "+pk)
+}
+
+// post the form with a valid CSRF token
+func postForm(r http.Handler, path string, form url.Values) *httptest.ResponseRecorder {
+	form.Set(csrfField, "token")
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "token"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	hookCalls = nil
+	accessor := &hookedAccessor{}
+	admin := NewAdmin()
+	admin.Register(NewModelAdmin("hooked", "Name", nil, nil, nil, nil, nil, nil, accessor, nil))
+	r := testRouter(admin)
+
+	if w := postForm(r, "/admin/hooked/add", url.Values{"Name": {"bob"}}); w.Code != http.StatusFound {
+		t.Errorf("save got status %d", w.Code)
+	}
+	if fmt.Sprint(accessor.saved) != "[BOB]" {
+		t.Errorf("PreSaveContext changes not saved: %v", accessor.saved)
+	}
+	if fmt.Sprint(hookCalls) != "[load saved new create]" {
+		t.Errorf("hook calls %v", hookCalls)
+	}
+
+	if w := postForm(r, "/admin/hooked/add", url.Values{"Name": {"veto"}}); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("vetoed save got status %d", w.Code)
+	}
+	if w := postForm(r, "/admin/hooked/bob", url.Values{"action": {"delete"}}); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("vetoed delete got status %d", w.Code)
+	}
+	if len(accessor.saved) != 1 {
+		t.Errorf("vetoed save reached the accessor")
+	}
+}
+
+type SluggedObject struct {
+	Name string
+	Slug string
+	Tags []string
+}
+
+func (s SluggedObject) PreSave() interface{} {
+	s.Slug = strings.ToLower(strings.TrimSpace(s.Name))
+	s.Tags = []string{"new"}
+	return s
+}
+func (s *SluggedObject) PreSaveContext(ctx context.Context) error {
+	s.Name = strings.TrimSpace(s.Name)
+	return nil
+}
+
+type upsertAccessor struct {
+	failingAccessor
+	values map[string][]string
+}
+
+func (u *upsertAccessor) Prototype() interface{} { return SluggedObject{} }
+func (u *upsertAccessor) Upsert(pk string, values map[string][]string) (string, error) {
+	u.values = values
+	return "1", nil
+}
+
+func TestPreSaveUpsert(t *testing.T) {
+	accessor := &upsertAccessor{}
+	admin := NewAdmin()
+	admin.Register(NewModelAdmin("slugged", "Name", nil, nil, nil, nil, nil, nil, accessor, nil))
+	r := testRouter(admin)
+
+	// the hooks' changes reach Upsert as form values
+	if w := postForm(r, "/admin/slugged/add", url.Values{"Name": {" Acme "}, "Tags.0": {"old"}}); w.Code != http.StatusFound {
+		t.Fatalf("save got status %d", w.Code)
+	}
+	obj, err := Decode(accessor.values, SluggedObject{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := *obj.(*SluggedObject); got.Name != "Acme" || got.Slug != "acme" || fmt.Sprint(got.Tags) != "[new]" {
+		t.Errorf("upserted %+v from %v", got, accessor.values)
+	}
+}
+
+func TestListActionFlash(t *testing.T) {
+	admin := NewAdmin()
+	ma := NewModelAdmin("test", "Name", nil, nil, nil, nil, nil, nil, failingAccessor{}, nil)
+	ma.AddListAction(NewMessageAdminAction("Archive", false, "", "",
+		func(ctx context.Context, values *url.Values) (string, error) {
+			return fmt.Sprintf("Archived %d items.", len((*values)["ids"])), nil
+		}))
+	ma.AddListAction(NewMessageAdminAction("Purge", false, "", "",
+		func(ctx context.Context, values *url.Values) (string, error) {
+			return "", errors.New("pq: password authentication failed")
+		}))
+	admin.SetLogger(log.New(io.Discard, "", 0))
+	admin.Register(ma)
+	r := testRouter(admin)
+
+	w := postForm(r, "/admin/test/?page=2", url.Values{"action": {"archive"}, "ids": {"1", "2"}})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/admin/test/?page=2" {
+		t.Fatalf("got status %d redirecting to %q", w.Code, w.Header().Get("Location"))
+	}
+	req := httptest.NewRequest("GET", "/admin/test/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	flashes := admin.popFlashes(c)
+	if len(flashes) != 1 || flashes[0].Level != FlashSuccess || flashes[0].Message != "Archived 2 items." {
+		t.Errorf("got flashes %+v", flashes)
+	}
+	if flashes = admin.popFlashes(c); len(flashes) != 0 {
+		t.Errorf("flashes not cleared: %+v", flashes)
+	}
+
+	// failures don't show internal error details
+	w = postForm(r, "/admin/test/", url.Values{"action": {"purge"}, "ids": {"1"}})
+	req = httptest.NewRequest("GET", "/admin/test/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	flashes = admin.popFlashes(c)
+	if len(flashes) != 1 || flashes[0].Level != FlashError || strings.Contains(flashes[0].Message, "password") {
+		t.Errorf("got flashes %+v", flashes)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	admin := NewAdmin()
+	accessor := &hookedAccessor{}
+	admin.Register(NewModelAdmin("hooked", "Name", nil, nil, nil, nil, nil, nil, accessor, nil))
+	r := testRouter(admin)
+
+	for _, header := range []string{"", "forged"} {
+		req := httptest.NewRequest("POST", "/admin/hooked/add", strings.NewReader("Name=bob&csrf_token="+header))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "token"})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("token %q: got status %d", header, w.Code)
+		}
+	}
+	if len(accessor.saved) != 0 {
+		t.Error("forged request was saved")
+	}
+	if w := postForm(r, "/admin/hooked/add", url.Values{"Name": {"bob"}}); w.Code != http.StatusFound {
+		t.Errorf("valid token got status %d", w.Code)
+	}
+
+	// a GET issues a token cookie
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/hooked/add", nil))
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != csrfCookie || cookies[0].Value == "" {
+		t.Errorf("expected a CSRF cookie, got %v", cookies)
+	}
+}
+
+type modelAuthenticator string
+
+func (m modelAuthenticator) IsAdmin(c *gin.Context) bool { return true }
+func (m modelAuthenticator) HasPrivilege(c *gin.Context, collection string, action string, ids []string) bool {
+	return collection == "" || collection == string(m)
+}
+
+func TestIndexHidesModels(t *testing.T) {
+	admin := NewAdmin()
+	admin.SetAuthenticator(modelAuthenticator("visible"))
+	for _, name := range []string{"visible", "hidden"} {
+		ma := NewModelAdmin(name, "Name", nil, nil, nil, nil, nil, nil, nil, nil)
+		ma.ContextAccessor = &userRecordingAccessor{}
+		admin.Register(ma)
+	}
+	r := testRouter(admin)
+	r.SetHTMLTemplate(template.Must(template.New("admin/index.html").Parse("{{range .admins}}{{.ModelName}} {{end}}")))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/", nil))
+	if got := w.Body.String(); got != "visible " {
+		t.Errorf("index listed %q", got)
+	}
+}
+
+type recordingAuthenticator struct {
+	checks []string
+}
+
+func (r *recordingAuthenticator) IsAdmin(c *gin.Context) bool { return true }
+func (r *recordingAuthenticator) HasPrivilege(c *gin.Context, collection string, action string, ids []string) bool {
+	r.checks = append(r.checks, fmt.Sprintf("%v %v %v", collection, action, ids))
+	return false
+}
+
+type loggedOutAuthenticator struct{}
+
+func (loggedOutAuthenticator) IsAdmin(c *gin.Context) bool { return false }
+func (loggedOutAuthenticator) HasPrivilege(c *gin.Context, collection string, action string, ids []string) bool {
+	return false
+}
+
+func TestPermissionStatus(t *testing.T) {
+	admin := NewAdmin()
+	admin.Register(NewModelAdmin("test", "Name", nil, nil, nil, nil, nil, nil, failingAccessor{}, nil))
+	r := testRouter(admin)
+	r.SetHTMLTemplate(template.Must(template.New("admin/error.html").Parse("{{.error}}")))
+	for _, tc := range []struct {
+		authenticator Authenticator
+		status        int
+	}{
+		{loggedOutAuthenticator{}, http.StatusUnauthorized},
+		{&recordingAuthenticator{}, http.StatusForbidden},
+	} {
+		admin.SetAuthenticator(tc.authenticator)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/test/", nil))
+		if w.Code != tc.status {
+			t.Errorf("%T: got status %d, want %d", tc.authenticator, w.Code, tc.status)
+		}
+	}
+}
+
+func TestPrivilegeIDs(t *testing.T) {
+	admin := NewAdmin()
+	ma := NewModelAdmin("test", "Name", nil, nil, nil, nil, nil, nil, failingAccessor{}, nil)
+	ma.AddListAction(NewAdminAction("Archive", false, "", "", func(values *url.Values) error { return nil }))
+	ma.AddListAction(NewAdminAction("Delete", false, "", "", func(values *url.Values) error { return nil }))
+	admin.Register(ma)
+	r := testRouter(admin)
+	cases := []struct {
+		path  string
+		form  url.Values
+		check string
+	}{
+		{"/admin/test/", url.Values{"action": {"archive"}, "ids": {"1", "2"}}, "test action:archive [1 2]"},
+		{"/admin/test/", url.Values{"action": {"delete"}, "ids": {"1"}}, "test action:delete [1]"},
+		{"/admin/test/", url.Values{"ids": {"1"}}, "test read [1]"},
+		{"/admin/test/3", url.Values{"Name": {"x"}}, "test write [3]"},
+		{"/admin/test/add", url.Values{"Name": {"x"}}, "test create []"},
+		{"/admin/test/3", url.Values{"action": {"delete"}}, "test delete [3]"},
+	}
+	for _, tc := range cases {
+		authenticator := &recordingAuthenticator{}
+		admin.SetAuthenticator(authenticator)
+		postForm(r, tc.path, tc.form)
+		// later checks come from rendering the error page's navbar
+		if len(authenticator.checks) == 0 || authenticator.checks[0] != tc.check {
+			t.Errorf("%v %v: checked %q, want %q", tc.path, tc.form, authenticator.checks, tc.check)
+		}
+	}
+}
+
+type Staff struct {
+	Name   string
+	Role   string
+	Salary int
+	Bank   struct{ Account string }
+}
+
+type staffAccessor struct {
+	failingAccessor
+	saved *Staff
+}
+
+func (s *staffAccessor) Prototype() interface{} { return Staff{} }
+func (s *staffAccessor) Get(pk string) (interface{}, error) {
+	staff := Staff{Name: pk, Role: "agent", Salary: 100}
+	staff.Bank.Account = "1234"
+	return staff, nil
+}
+func (s *staffAccessor) Save(pk string, obj interface{}) (string, error) {
+	s.saved = obj.(*Staff)
+	return pk, nil
+}
+
+type supportAuthenticator struct{}
+
+func (supportAuthenticator) IsAdmin(c *gin.Context) bool { return true }
+func (supportAuthenticator) HasPrivilege(c *gin.Context, collection string, action string, ids []string) bool {
+	return true
+}
+func (supportAuthenticator) FieldPermissions(c *gin.Context, collection string) (hidden map[string]bool, readOnly map[string]bool) {
+	return map[string]bool{"Salary": true, "Bank": true}, map[string]bool{"Role": true}
+}
+
+// viewerAuthenticator only grants PrivilegeView
+type viewerAuthenticator struct{ fixedUserAuthenticator }
+
+func (viewerAuthenticator) HasPrivilege(c *gin.Context, collection string, action string, ids []string) bool {
+	return action == PrivilegeView
+}
+
+func TestViewOnly(t *testing.T) {
+	admin := NewAdmin()
+	admin.SetAuthenticator(viewerAuthenticator{"acme"})
+	ma := NewModelAdmin("tenanted", "Name", nil, nil, nil, nil, nil, sprintPK{}, nil, nil)
+	ma.ContextAccessor = &tenantAccessor{}
+	admin.Register(ma)
+	r := testRouter(admin)
+	tmpl := template.Must(template.New("admin/change.html").Parse(
+		`{{.viewOnly}} {{.canDelete}} {{index .modelAdmin.ReadOnlyFields "Name"}} {{index .modelAdmin.ReadOnlyFields "Tenant"}}`))
+	template.Must(tmpl.New("admin/error.html").Parse("{{.error}}"))
+	r.SetHTMLTemplate(tmpl)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/tenanted/a", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "true false true true" {
+		t.Errorf("got status %d: %q", w.Code, w.Body.String())
+	}
+	if w := postForm(r, "/admin/tenanted/a", url.Values{"Tenant": {"globex"}}); w.Code != http.StatusForbidden {
+		t.Errorf("save got status %d", w.Code)
+	}
+}
+
+func TestFieldPermissions(t *testing.T) {
+	admin := NewAdmin()
+	admin.SetAuthenticator(supportAuthenticator{})
+	accessor := &staffAccessor{}
+	admin.Register(NewModelAdmin("staff", "Name", map[string]bool{"Name": false, "Salary": true}, nil, nil, nil, nil, nil, accessor, nil))
+	r := testRouter(admin)
+	tmpl := template.Must(template.New("admin/change.html").Parse(
+		`{{range $k, $v := .values}}{{$k}}={{$v}};{{end}}{{index .modelAdmin.ReadOnlyFields "Role"}}`))
+	template.Must(tmpl.New("admin/error.html").Parse("{{.error}}"))
+	r.SetHTMLTemplate(tmpl)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/staff/bob", nil))
+	if got := w.Body.String(); got != "Name=bob;Role=agent;true" {
+		t.Errorf("change form rendered %q", got)
+	}
+	if ma := admin.modelAdmins["staff"]; ma.OmitFields["Salary"] || ma.ReadOnlyFields["Role"] {
+		t.Error("field permissions leaked into the registered ModelAdmin")
+	}
+
+	postForm(r, "/admin/staff/bob", url.Values{"Name": {"bob"}, "Role": {"admin"}, "Salary": {"1000"}, "Bank.Account": {"666"}})
+	if accessor.saved == nil {
+		t.Fatal("not saved")
+	}
+	if s := accessor.saved; s.Role != "agent" || s.Salary != 100 || s.Bank.Account != "1234" {
+		t.Errorf("restricted fields were changed: %+v", s)
+	}
+
+	// hidden fields can't be sorted on, which would reveal their order
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/staff/?o=-Salary", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("sorting by a hidden field got status %d: %q", w.Code, w.Body.String())
+	}
+}
+
+type hookedStaff struct {
+	staffAccessor
+	preSaved string
+}
+
+func (h *hookedStaff) PreSaveObject(ctx context.Context, pk string, obj interface{}) error {
+	h.preSaved = pk
+	return nil
+}
+
+func TestAdaptAccessor(t *testing.T) {
+	admin := NewAdmin()
+	accessor := &hookedStaff{}
+	ma := NewModelAdmin("staff", "Name", nil, nil, nil, nil, nil, nil, nil, nil)
+	ma.ContextAccessor = AdaptAccessor(accessor)
+	admin.Register(ma)
+	r := testRouter(admin)
+	r.SetHTMLTemplate(template.Must(template.New("admin/error.html").Parse("{{.error}}")))
+
+	// the adapted accessor's Save and hooks are used as if it were set as the Accessor
+	if w := postForm(r, "/admin/staff/bob", url.Values{"Name": {"bob"}, "Role": {"admin"}}); w.Code != http.StatusFound {
+		t.Fatalf("save got status %d: %v", w.Code, w.Body.String())
+	}
+	if accessor.saved == nil || accessor.saved.Role != "admin" {
+		t.Errorf("saved %+v", accessor.saved)
+	}
+	if accessor.preSaved != "bob" {
+		t.Errorf("PreSaveObject hook got %q", accessor.preSaved)
+	}
+}
+
+type Tenanted struct {
+	Name   string
+	Tenant string
+}
+
+type tenantAccessor struct {
+	scopes []Scope
+	saved  *Tenanted
+}
+
+var tenanted = []Tenanted{{"a", "acme"}, {"b", "globex"}}
+
+func (t *tenantAccessor) Prototype() interface{} { return Tenanted{} }
+func (t *tenantAccessor) Get(ctx context.Context, pk string) (interface{}, error) {
+	t.scopes = append(t.scopes, ScopeFromContext(ctx))
+	for _, obj := range tenanted {
+		if obj.Name == pk {
+			return obj, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+func (t *tenantAccessor) List(ctx context.Context, count, page int, order []Order) (interface{}, error) {
+	t.scopes = append(t.scopes, ScopeFromContext(ctx))
+	return tenanted, nil
+}
+func (t *tenantAccessor) Count(ctx context.Context) (int, error) { return len(tenanted), nil }
+func (t *tenantAccessor) Upsert(ctx context.Context, pk string, values map[string][]string) (string, error) {
+	return "", nil
+}
+func (t *tenantAccessor) DeletePK(ctx context.Context, pk string) error { return nil }
+func (t *tenantAccessor) Save(ctx context.Context, pk string, obj interface{}) (string, error) {
+	t.saved = obj.(*Tenanted)
+	return t.saved.Name, nil
+}
+
+type sprintPK struct{}
+
+func (sprintPK) PKString(pk interface{}) string { return fmt.Sprint(pk) }
+
+func TestScope(t *testing.T) {
+	admin := NewAdmin()
+	admin.SetAuthenticator(fixedUserAuthenticator("acme"))
+	accessor := &tenantAccessor{}
+	ma := NewModelAdmin("tenanted", "Name", map[string]bool{"Name": false}, nil, nil, nil, nil, sprintPK{}, nil, nil)
+	ma.ContextAccessor = accessor
+	ma.ScopeFunc = func(c *gin.Context) Scope {
+		return Scope{"Tenant": c.GetString(usernameKey)}
+	}
+	admin.Register(ma)
+	r := testRouter(admin)
+	tmpl := template.Must(template.New("admin/list.html").Parse("{{range .pks}}{{.}} {{end}}"))
+	template.Must(tmpl.New("admin/change.html").Parse("{{.values.Tenant}}"))
+	template.Must(tmpl.New("admin/error.html").Parse("{{.error}}"))
+	r.SetHTMLTemplate(tmpl)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/tenanted/", nil))
+	if got := w.Body.String(); got != "a " {
+		t.Errorf("list showed %q", got)
+	}
+	for pk, status := range map[string]int{"a": http.StatusOK, "b": http.StatusNotFound} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/tenanted/"+pk, nil))
+		if w.Code != status {
+			t.Errorf("%v: got status %d, want %d", pk, w.Code, status)
+		}
+	}
+	for _, scope := range accessor.scopes {
+		if scope["Tenant"] != "acme" {
+			t.Errorf("accessor got scope %v", scope)
+		}
+	}
+
+	if w = postForm(r, "/admin/tenanted/b", url.Values{"Name": {"b"}}); w.Code != http.StatusNotFound {
+		t.Errorf("saving out of scope object got status %d", w.Code)
+	}
+	postForm(r, "/admin/tenanted/add", url.Values{"Name": {"c"}, "Tenant": {"globex"}})
+	if accessor.saved == nil || accessor.saved.Tenant != "acme" {
+		t.Errorf("saved %+v", accessor.saved)
+	}
+}
+
+type recordingAuditLog struct {
+	entries []AuditEntry
+}
+
+func (r *recordingAuditLog) Record(ctx context.Context, entry AuditEntry) error {
+	r.entries = append([]AuditEntry{entry}, r.entries...)
+	return nil
+}
+func (r *recordingAuditLog) Entries(ctx context.Context, model string, pk string, limit int) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	for _, entry := range r.entries {
+		if (model == "" || entry.Model == model) && (pk == "" || entry.PK == pk) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func TestAudit(t *testing.T) {
+	admin := NewAdmin()
+	admin.SetAuthenticator(fixedUserAuthenticator("alice"))
+	auditLog := &recordingAuditLog{}
+	admin.SetAuditLog(auditLog)
+	accessor := &staffAccessor{}
+	admin.Register(NewModelAdmin("staff", "Name", nil, nil, nil, nil, nil, nil, accessor, nil))
+	r := testRouter(admin)
+	tmpl := template.Must(template.New("admin/history.html").Parse(
+		`{{range .entries}}{{.Username}} {{.Action}}{{range .Changes}} {{.Field}}:{{.Before}}>{{.After}}{{end}};{{end}}`))
+	template.Must(tmpl.New("admin/index.html").Parse(`{{range .recent}}{{.Action}} {{.PK}};{{end}}`))
+	r.SetHTMLTemplate(tmpl)
+
+	postForm(r, "/admin/staff/bob", url.Values{"Name": {"bob"}, "Role": {"lead"}})
+	postForm(r, "/admin/staff/bob", url.Values{"action": {"delete"}})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/staff/bob/history", nil))
+	if got := w.Body.String(); !strings.HasPrefix(got, "alice delete Bank:") || !strings.HasSuffix(got, "Salary:100>;alice change Role:agent>lead;") {
+		t.Errorf("history page showed %q", got)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/", nil))
+	if got := w.Body.String(); got != "delete bob;change bob;" {
+		t.Errorf("recent actions showed %q", got)
+	}
+}
+
+type memoryVersions []Version
+
+func (m *memoryVersions) AddVersion(ctx context.Context, version Version) error {
+	version.Number = len(*m) + 1
+	*m = append(memoryVersions{version}, *m...)
+	return nil
+}
+func (m *memoryVersions) Versions(ctx context.Context, model string, pk string) ([]Version, error) {
+	return *m, nil
+}
+
+func TestVersions(t *testing.T) {
+	admin := NewAdmin()
+	versions := &memoryVersions{}
+	admin.SetVersionStore(versions)
+	accessor := &staffAccessor{}
+	admin.Register(NewModelAdmin("staff", "Name", nil, nil, nil, nil, nil, nil, accessor, nil))
+	r := testRouter(admin)
+	tmpl := template.Must(template.New("admin/versions.html").Parse(
+		`{{range .versions}}{{.Number}};{{end}}{{range .changes}}{{.Field}}:{{.Before}}>{{.After}};{{end}}`))
+	template.Must(tmpl.New("admin/change.html").Parse(`{{.formError}}`))
+	template.Must(tmpl.New("admin/error.html").Parse(`{{.error}}`))
+	r.SetHTMLTemplate(tmpl)
+
+	postForm(r, "/admin/staff/bob", url.Values{"Role": {"lead"}})
+	postForm(r, "/admin/staff/bob", url.Values{"Role": {"manager"}})
+	if len(*versions) != 2 || (*versions)[0].Values["Role"] != "agent" {
+		t.Fatalf("stored versions %+v", *versions)
+	}
+	(*versions)[0].Values["Role"] = "lead" // as if the accessor had stored the first change
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/staff/bob/versions?a=2&b=0", nil))
+	if got := w.Body.String(); got != "2;1;Role:lead>agent;" {
+		t.Errorf("versions page showed %q", got)
+	}
+
+	w = postForm(r, "/admin/staff/bob/versions", url.Values{"version": {"2"}})
+	if w.Code != http.StatusFound || accessor.saved.Role != "lead" || accessor.saved.Bank.Account != "1234" {
+		t.Errorf("revert got status %d and saved %+v", w.Code, accessor.saved)
+	}
+	if w = postForm(r, "/admin/staff/bob/versions", url.Values{"version": {"9"}}); w.Code != http.StatusNotFound {
+		t.Errorf("reverting to a missing version got status %d", w.Code)
+	}
+}
+
+type Note struct {
+	Name  string
+	Title string
+	Body  string
+}
+
+type noteAccessor struct {
+	failingAccessor
+	note Note
+}
+
+func (n *noteAccessor) Prototype() interface{}             { return Note{} }
+func (n *noteAccessor) Get(pk string) (interface{}, error) { return n.note, nil }
+func (n *noteAccessor) Save(pk string, obj interface{}) (string, error) {
+	n.note = *obj.(*Note)
+	return pk, nil
+}
+
+func TestConflicts(t *testing.T) {
+	admin := NewAdmin()
+	accessor := &noteAccessor{note: Note{"n", "title", "body"}}
+	ma := NewModelAdmin("note", "Name", nil, nil, nil, nil, nil, nil, accessor, nil)
+	ma.DetectConflicts = true
+	admin.Register(ma)
+	r := testRouter(admin)
+	tmpl := template.Must(template.New("admin/change.html").Parse(`{{.version}} {{.original}}`))
+	template.Must(tmpl.New("admin/conflict.html").Parse(
+		`{{range .conflicts}}{{.Field}}:{{.Theirs}}/{{.Yours}}/{{.Conflict}};{{end}}|{{.version}}|{{.original}}`))
+	r.SetHTMLTemplate(tmpl)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/note/n", nil))
+	form := strings.Fields(w.Body.String())
+	accessor.note.Title = "their title"
+
+	w = postForm(r, "/admin/note/n", url.Values{"Title": {"title"}, "Body": {"my body"},
+		versionField: {form[0]}, originalField: {form[1]}})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("got status %d", w.Code)
+	}
+	page := strings.Split(w.Body.String(), "|")
+	if page[0] != "Body:body/my body/false;Title:their title/title/false;" {
+		t.Errorf("conflict page showed %q", page[0])
+	}
+	if accessor.note.Body != "body" {
+		t.Errorf("conflicting change saved: %+v", accessor.note)
+	}
+
+	// merge by submitting only the user's changes
+	w = postForm(r, "/admin/note/n", url.Values{"Body": {"my body"}, versionField: {page[1]}, originalField: {page[2]}})
+	if w.Code != http.StatusFound || accessor.note != (Note{"n", "their title", "my body"}) {
+		t.Errorf("merge got status %d and saved %+v", w.Code, accessor.note)
+	}
+}
+
+type filteredAccessor struct {
+	tenantAccessor
+	conditions []Condition
+}
+
+func (f *filteredAccessor) ListFiltered(ctx context.Context, count, page int, order []Order, conditions []Condition) (interface{}, error) {
+	f.conditions = conditions
+	return tenanted, nil
+}
+func (f *filteredAccessor) CountFiltered(ctx context.Context, conditions []Condition) (int, error) {
+	return len(tenanted), nil
+}
+
+func TestFilters(t *testing.T) {
+	admin := NewAdmin()
+	admin.SetAuthenticator(fixedUserAuthenticator("acme"))
+	accessor := &filteredAccessor{}
+	ma := NewModelAdmin("tenanted", "Name", map[string]bool{"Name": true}, nil, nil, nil, nil, sprintPK{}, nil, nil)
+	ma.ContextAccessor = accessor
+	ma.ListFilters = []ListFilter{
+		{Field: "Active", Kind: FilterBool},
+		{Field: "Tenant", Kind: FilterChoices, Choices: []FilterChoice{{"acme", "Acme"}, {"globex", "Globex"}}},
+		{Field: "Created", Kind: FilterDateRange},
+		{Field: "Seats", Kind: FilterNumberRange},
+		{Field: "Deleted", Kind: FilterNull},
+		{Field: "size", Kind: FilterCustom, Choices: []FilterChoice{{Value: "large"}}, Conditions: func(value string) ([]Condition, error) {
+			return []Condition{{"Seats", OpGreaterOrEqual, 100.0}}, nil
+		}},
+	}
+	admin.Register(ma)
+	r := testRouter(admin)
+	tmpl := template.Must(template.New("admin/list.html").Parse(
+		"{{range .filters}}{{range .Links}}{{if .Selected}}{{.URL}}|{{end}}{{end}}{{end}}"))
+	template.Must(tmpl.New("admin/error.html").Parse("{{.error}}"))
+	r.SetHTMLTemplate(tmpl)
+
+	query := "f.Active=true&f.Tenant=acme&f.Created.gte=2020-01-01&f.Created.lte=2020-01-31" +
+		"&f.Seats.lte=10&f.Deleted=null&f.size=large&o=Name"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/tenanted/?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %v", w.Code, w.Body.String())
+	}
+	want := []Condition{
+		{"Active", OpEqual, true},
+		{"Tenant", OpEqual, "acme"},
+		{"Created", OpGreaterOrEqual, time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"Created", OpLess, time.Date(2020, 2, 1, 0, 0, 0, 0, time.Local)},
+		{"Seats", OpLessOrEqual, 10.0},
+		{Field: "Deleted", Op: OpIsNull},
+		{"Seats", OpGreaterOrEqual, 100.0},
+	}
+	if fmt.Sprint(accessor.conditions) != fmt.Sprint(want) {
+		t.Errorf("got conditions %v, want %v", accessor.conditions, want)
+	}
+	// the filters' links keep the other filters and the sort order
+	links := strings.Split(strings.TrimSuffix(w.Body.String(), "|"), "|")
+	if len(links) != 4 {
+		t.Fatalf("got selected links %q", links)
+	}
+	q, _ := url.ParseQuery(strings.TrimPrefix(strings.Replace(links[0], "&amp;", "&", -1), "?"))
+	if q.Get("f.Active") != "true" || q.Get("f.Tenant") != "acme" || q.Get("o") != "Name" {
+		t.Errorf("Yes link of active filter was %q", links[0])
+	}
+
+	for _, bad := range []string{"f.Active=maybe", "f.Tenant=initech", "f.Created.gte=yesterday", "f.Seats.lte=ten", "f.Deleted=0",
+		"f.Password=secret", "f.Active.gte=true", "f.Created=2020-01-01"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/tenanted/?"+bad, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%v: got status %d", bad, w.Code)
+		}
+	}
+
+	// page links keep the filters too
+	tmpl = template.New("").Funcs(template.FuncMap{"lower": strings.ToLower})
+	ParseTemplates(tmpl)
+	r.SetHTMLTemplate(tmpl)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/tenanted/?f.Active=true", nil))
+	pageLinks := regexp.MustCompile(`href="(\?page=[^"]*)"`).FindAllStringSubmatch(w.Body.String(), -1)
+	if len(pageLinks) != 3 {
+		t.Fatalf("got page links %q", pageLinks)
+	}
+	for _, link := range pageLinks {
+		if !strings.Contains(link[1], "f.Active=true") {
+			t.Errorf("page link %q lost the filters", link[1])
+		}
+	}
+}
+
+func TestUnfilteredAccessor(t *testing.T) {
+	admin := NewAdmin()
+	admin.SetAuthenticator(fixedUserAuthenticator("acme"))
+	ma := NewModelAdmin("tenanted", "Name", map[string]bool{"Name": true}, nil, nil, nil, nil, sprintPK{}, nil, nil)
+	ma.ContextAccessor = &tenantAccessor{}
+	ma.ListFilters = []ListFilter{{Field: "Tenant", Kind: FilterChoices, Choices: []FilterChoice{{Value: "acme"}}}}
+	var searched []Condition
+	searchContext := func(ctx context.Context, count, page int, query string, order []Order) (interface{}, int, error) {
+		searched = FiltersFromContext(ctx)
+		return tenanted, len(tenanted), nil
+	}
+	search := func(count, page int, query string, order []Order) (interface{}, int, error) {
+		return tenanted, len(tenanted), nil
+	}
+	admin.Register(ma)
+	r := testRouter(admin)
+	tmpl := template.Must(template.New("admin/list.html").Parse("{{range .filters}}{{.Field}} {{end}}"))
+	template.Must(tmpl.New("admin/error.html").Parse("{{.error}}"))
+	r.SetHTMLTemplate(tmpl)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/tenanted/"+query, nil))
+		return w
+	}
+	setSearcher := func(searcher *Searcher) {
+		registered := admin.modelAdmins["tenanted"]
+		registered.Searcher = searcher
+		admin.modelAdmins["tenanted"] = registered
+	}
+
+	// without a FilterAccessor the filters can't be applied, so aren't offered
+	if w := get(""); w.Code != http.StatusOK || w.Body.String() != "" {
+		t.Errorf("got %q", w.Body.String())
+	}
+	if w := get("?f.Tenant=acme"); w.Code != http.StatusBadRequest {
+		t.Errorf("unapplied filter got status %d", w.Code)
+	}
+
+	// a SearchContext gets the filters while searching, but a context-free Search can't
+	setSearcher(&Searcher{SearchContext: searchContext})
+	if w := get("?q=a&f.Tenant=acme"); w.Code != http.StatusOK || w.Body.String() != "Tenant " {
+		t.Errorf("filtered search got status %d: %q", w.Code, w.Body.String())
+	}
+	if len(searched) != 1 || searched[0].Value != "acme" {
+		t.Errorf("searched with %v", searched)
+	}
+	setSearcher(&Searcher{Search: search})
+	if w := get("?q=a&f.Tenant=acme"); w.Code != http.StatusBadRequest {
+		t.Errorf("unapplied filter on a search got status %d", w.Code)
+	}
+}
+
+type orderAccessor struct {
+	tenantAccessor
+	order []Order
+}
+
+func (o *orderAccessor) List(ctx context.Context, count, page int, order []Order) (interface{}, error) {
+	o.order = order
+	return tenanted, nil
+}
+
+func TestListOrder(t *testing.T) {
+	admin := NewAdmin()
+	admin.SetAuthenticator(fixedUserAuthenticator("acme"))
+	accessor := &orderAccessor{}
+	ma := NewModelAdmin("tenanted", "Name", map[string]bool{"Name": true, "Tenant": true}, nil, nil, nil, nil, sprintPK{}, nil, nil)
+	ma.ContextAccessor = accessor
+	ma.DefaultOrder = []Order{{"Tenant", true}}
+	ma.FieldColumns = map[string]string{"Tenant": "tenant_id"}
+	admin.Register(ma)
+	r := testRouter(admin)
+	tmpl := template.Must(template.New("admin/list.html").Parse(
+		"{{range $field, $p := .priorities}}{{$field}}:{{$p}}:{{index $.orders $field}} {{end}}"))
+	template.Must(tmpl.New("admin/error.html").Parse("{{.error}}"))
+	r.SetHTMLTemplate(tmpl)
+
+	for query, want := range map[string]struct {
+		order      []Order
+		priorities string
+	}{
+		"":                       {[]Order{{"tenant_id", true}}, ""},
+		"?o=":                    {nil, ""},
+		"?o=-Tenant":             {[]Order{{"tenant_id", false}}, ""},
+		"?o=-Tenant,Name,Tenant": {[]Order{{"tenant_id", false}, {"Name", true}}, "Name:2:1 Tenant:1:-1 "},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/tenanted/"+query, nil))
+		if fmt.Sprint(accessor.order) != fmt.Sprint(want.order) {
+			t.Errorf("%q: got order %v, want %v", query, accessor.order, want.order)
+		}
+		if got := w.Body.String(); got != want.priorities {
+			t.Errorf("%q: got priorities %q, want %q", query, got, want.priorities)
+		}
+	}
+	// every page link keeps the sort, even an empty one turning off the default order
+	tmpl = template.New("").Funcs(template.FuncMap{"lower": strings.ToLower})
+	ParseTemplates(tmpl)
+	r.SetHTMLTemplate(tmpl)
+	for query, want := range map[string]string{"?o=": "&o=\"", "?o=-Tenant": "&o=-Tenant\""} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/tenanted/"+query, nil))
+		pageLinks := regexp.MustCompile(`href="\?page=[^"]*"`).FindAllString(w.Body.String(), -1)
+		if len(pageLinks) != 3 {
+			t.Fatalf("%q: got page links %q", query, pageLinks)
+		}
+		for _, link := range pageLinks {
+			if !strings.HasSuffix(link, want) {
+				t.Errorf("%q: page link %v lost the sort", query, link)
+			}
+		}
+	}
+
+	// only sortable list fields can be sorted by
+	admin.modelAdmins["tenanted"].ListFields["Tenant"] = false
+	for _, sort := range []string{"Tenant", "Name,-Secret", "Name;DROP TABLE"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/tenanted/?o="+url.QueryEscape(sort), nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: got status %d", sort, w.Code)
+		}
+	}
+}
+
+type typedTenants struct {
+	saved      *Tenanted
+	conditions []Condition
+}
+
+func (t *typedTenants) Get(ctx context.Context, pk string) (Tenanted, error) {
+	for _, obj := range tenanted {
+		if obj.Name == pk {
+			return obj, nil
+		}
+	}
+	return Tenanted{}, ErrNotFound
+}
+func (t *typedTenants) List(ctx context.Context, count, page int, order []Order) ([]Tenanted, error) {
+	return tenanted, nil
+}
+func (t *typedTenants) Count(ctx context.Context) (int, error) { return len(tenanted), nil }
+func (t *typedTenants) Upsert(ctx context.Context, pk string, values map[string][]string) (string, error) {
+	return "", errors.New("Save should be used")
+}
+func (t *typedTenants) DeletePK(ctx context.Context, pk string) error { return nil }
+func (t *typedTenants) Save(ctx context.Context, pk string, obj *Tenanted) (string, error) {
+	t.saved = obj
+	return obj.Name, nil
+}
+func (t *typedTenants) ListFiltered(ctx context.Context, count, page int, order []Order, conditions []Condition) ([]Tenanted, error) {
+	t.conditions = conditions
+	if len(conditions) == 0 {
+		return tenanted, nil
+	}
+	return tenanted[:1], nil
+}
+func (t *typedTenants) CountFiltered(ctx context.Context, conditions []Condition) (int, error) {
+	return 1, nil
+}
+
+type nonSliceAccessor struct{ tenantAccessor }
+
+func (n *nonSliceAccessor) List(ctx context.Context, count, page int, order []Order) (interface{}, error) {
+	return tenanted[0], nil
+}
+
+func TestTypedAccessor(t *testing.T) {
+	admin := NewAdmin()
+	admin.SetLogger(log.New(io.Discard, "", 0))
+	accessor := &typedTenants{}
+	ma := NewTypedModelAdmin[Tenanted]("tenanted", "Name", sprintPK{}, accessor)
+	ma.ListFields = map[string]bool{"Name": true}
+	ma.ListFilters = []ListFilter{{Field: "Tenant", Kind: FilterChoices, Choices: []FilterChoice{{Value: "acme"}}}}
+	admin.Register(ma)
+	admin.Register(ModelAdmin{ModelName: "broken", PKFieldName: "Name", PKStringer: sprintPK{},
+		ListActions: map[string]*AdminAction{}, ContextAccessor: &nonSliceAccessor{}})
+	r := testRouter(admin)
+	tmpl := template.Must(template.New("admin/list.html").Parse("{{range .pks}}{{.}} {{end}}"))
+	template.Must(tmpl.New("admin/change.html").Parse("{{.error}}"))
+	template.Must(tmpl.New("admin/error.html").Parse("{{.error}}"))
+	r.SetHTMLTemplate(tmpl)
+
+	if proto, ok := ma.accessor().Prototype().(Tenanted); !ok || proto != (Tenanted{}) {
+		t.Errorf("got prototype %#v", proto)
+	}
+	for _, query := range []string{"", "?f.Tenant=acme"} {
+		want := map[string]string{"": "a b ", "?f.Tenant=acme": "a "}[query]
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/tenanted/"+query, nil))
+		if got := w.Body.String(); got != want {
+			t.Errorf("%q: got %q, want %q", query, got, want)
+		}
+	}
+	if len(accessor.conditions) != 1 || accessor.conditions[0].Value != "acme" {
+		t.Errorf("filtered with %v", accessor.conditions)
+	}
+	if w := postForm(r, "/admin/tenanted/a", url.Values{"Tenant": {"initech"}}); w.Code != http.StatusFound {
+		t.Errorf("save got status %d: %v", w.Code, w.Body.String())
+	}
+	if accessor.saved == nil || *accessor.saved != (Tenanted{"a", "initech"}) {
+		t.Errorf("saved %+v", accessor.saved)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/broken/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("non-slice list got status %d", w.Code)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("TypedAccessor of a pointer type accepted")
+			}
+		}()
+		NewTypedModelAdmin[*Tenanted]("pointers", "Name", sprintPK{}, pointerTenants{})
+	}()
+}
+
+// a TypedAccessor of pointers, which godmin can't use
+type pointerTenants struct{}
+
+func (pointerTenants) Get(ctx context.Context, pk string) (*Tenanted, error) { return nil, ErrNotFound }
+func (pointerTenants) List(ctx context.Context, count, page int, order []Order) ([]*Tenanted, error) {
+	return nil, nil
+}
+func (pointerTenants) Count(ctx context.Context) (int, error) { return 0, nil }
+func (pointerTenants) Upsert(ctx context.Context, pk string, values map[string][]string) (string, error) {
+	return "", nil
+}
+func (pointerTenants) DeletePK(ctx context.Context, pk string) error { return nil }
+
+type Appointment struct {
+	Title string
+	At    time.Time
+	Ends  *time.Time
+	Seats int
+}
+
+func TestValuesRoundTrip(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 6, time.FixedZone("PST", -8*3600))
+	ends := at.Add(time.Hour)
+	obj := Appointment{Title: "Review", At: at, Ends: &ends, Seats: 3}
+	values := ValuesMapper(obj)
+	form := make(map[string][]string, len(values))
+	for field, value := range values {
+		form[field] = []string{value}
+	}
+	decoded, err := Decode(form, Appointment{})
+	if err != nil {
+		t.Fatalf("decoding %v: %v", values, err)
+	}
+	got := decoded.(*Appointment)
+	if got.Title != obj.Title || !got.At.Equal(at) || got.Ends == nil || !got.Ends.Equal(ends) || got.Seats != 3 {
+		t.Errorf("round trip of %+v got %+v", obj, got)
+	}
+}
+
+func TestRealTemplates(t *testing.T) {
+	admin := NewAdmin()
+	admin.SetAuthenticator(fixedUserAuthenticator("acme"))
+	ma := NewModelAdmin("tenanted", "Name", map[string]bool{"Name": true}, nil, nil, nil, nil, sprintPK{}, nil, nil)
+	ma.ContextAccessor = &tenantAccessor{}
+	admin.Register(ma)
+	r := testRouter(admin)
+	// host applications supply lower, which the templates use for model paths
+	tmpl := template.New("").Funcs(template.FuncMap{"lower": strings.ToLower})
+	ParseTemplates(tmpl)
+	r.SetHTMLTemplate(tmpl)
+
+	for _, path := range []string{"/admin/", "/admin/tenanted/", "/admin/tenanted/a", "/admin/tenanted/add"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		body := strings.TrimSpace(w.Body.String())
+		if w.Code != http.StatusOK || !strings.HasSuffix(body, "</html>") {
+			t.Errorf("%v: got status %d and a truncated page", path, w.Code)
+		}
+	}
+}
@@ -1,5 +1,82 @@
+// Package hooks defines optional lifecycle interfaces that the admin calls while
+// loading, saving and deleting objects.
+//
+// Model hooks are implemented by the administered type itself and are called on a
+// pointer to the object, so they may modify it. Object hooks are implemented by a
+// ModelAdmin's Hooks value (or its Accessor if Hooks isn't set) and receive the object.
+//
+// Returning an error from a Pre hook vetoes the operation, and the error's message is
+// shown to the admin user. Changes made by the PreSave hooks are saved; Accessors without
+// Save receive the changed fields along with the submitted form values passed to Upsert.
 package hooks
 
+import (
+	"context"
+)
+
+// PreSave is called before the object is saved, ahead of PreSaveContext,
+// and the object it returns is saved in its place.
 type PreSave interface {
 	PreSave() interface{}
 }
+
+// PreSaveContext is called before the object is saved.
+type PreSaveContext interface {
+	PreSaveContext(ctx context.Context) error
+}
+
+// PostSave is called after the object is saved.
+type PostSave interface {
+	PostSave(ctx context.Context)
+}
+
+// PostCreate is called after a new object is saved, following PostSave.
+type PostCreate interface {
+	PostCreate(ctx context.Context)
+}
+
+// PreDelete is called before the object is deleted.
+type PreDelete interface {
+	PreDelete(ctx context.Context) error
+}
+
+// PostDelete is called after the object is deleted.
+type PostDelete interface {
+	PostDelete(ctx context.Context)
+}
+
+// OnLoad is called when the object is loaded for display or editing,
+// including the empty object shown in the add form.
+type OnLoad interface {
+	OnLoad(ctx context.Context)
+}
+
+// PreSaveObject is called before obj is saved. pk is empty for new objects.
+type PreSaveObject interface {
+	PreSaveObject(ctx context.Context, pk string, obj interface{}) error
+}
+
+// PostSaveObject is called after obj is saved with primary key pk.
+type PostSaveObject interface {
+	PostSaveObject(ctx context.Context, pk string, obj interface{})
+}
+
+// PostCreateObject is called after a new obj is saved with primary key pk, following PostSaveObject.
+type PostCreateObject interface {
+	PostCreateObject(ctx context.Context, pk string, obj interface{})
+}
+
+// PreDeleteObject is called before obj is deleted.
+type PreDeleteObject interface {
+	PreDeleteObject(ctx context.Context, pk string, obj interface{}) error
+}
+
+// PostDeleteObject is called after obj is deleted.
+type PostDeleteObject interface {
+	PostDeleteObject(ctx context.Context, pk string, obj interface{})
+}
+
+// OnLoadObject is called when obj is loaded for display or editing.
+type OnLoadObject interface {
+	OnLoadObject(ctx context.Context, obj interface{})
+}
@@ -0,0 +1,24 @@
+// Package sqltest opens in-memory SQLite databases for the tests of the SQL stores.
+package sqltest
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Open returns an in-memory SQLite database created with the schema, closed when the test ends.
+func Open(t *testing.T, schema string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // every connection gets its own in-memory database
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
@@ -0,0 +1,142 @@
+package godmin
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/gpitfield/godmin/hooks"
+)
+
+// hookError marks an error returned by a hook vetoing an operation
+type hookError struct {
+	err error
+}
+
+func (h hookError) Error() string { return h.err.Error() }
+func (h hookError) Unwrap() error { return h.err }
+func (h hookError) Is(target error) bool {
+	return target == ErrVetoed
+}
+
+func vetoed(err error) error {
+	if err == nil {
+		return nil
+	}
+	return hookError{err}
+}
+
+// the value implementing the ModelAdmin's object hooks
+func (m *ModelAdmin) hooks() interface{} {
+	if m.Hooks != nil {
+		return m.Hooks
+	}
+	accessor := m.accessor()
+	if optional, ok := accessor.(optionalAccessor); ok {
+		return optional.hooks()
+	}
+	return accessor
+}
+
+// a pointer to a copy of the struct obj or obj points to, or obj itself if it isn't one
+func copyStruct(obj interface{}) interface{} {
+	v := reflect.ValueOf(obj)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return obj
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	return ptr.Interface()
+}
+
+// load returns a pointer to a copy of obj after calling its OnLoad hooks
+func (m *ModelAdmin) load(ctx context.Context, obj interface{}) interface{} {
+	obj = copyStruct(obj)
+	if reflect.ValueOf(obj).Kind() != reflect.Ptr {
+		return obj
+	}
+	if h, ok := obj.(hooks.OnLoad); ok {
+		h.OnLoad(ctx)
+	}
+	if h, ok := m.hooks().(hooks.OnLoadObject); ok {
+		h.OnLoadObject(ctx, obj)
+	}
+	return obj
+}
+
+// preSave calls the save hooks on obj, returning the object to save in its place
+func (m *ModelAdmin) preSave(ctx context.Context, pk string, obj interface{}) (interface{}, error) {
+	if h, ok := obj.(hooks.PreSave); ok {
+		if saved := h.PreSave(); saved != nil {
+			obj = copyStruct(saved)
+		}
+	}
+	if h, ok := obj.(hooks.PreSaveContext); ok {
+		if err := h.PreSaveContext(ctx); err != nil {
+			return obj, vetoed(err)
+		}
+	}
+	if h, ok := m.hooks().(hooks.PreSaveObject); ok {
+		return obj, vetoed(h.PreSaveObject(ctx, pk, obj))
+	}
+	return obj, nil
+}
+
+// hookedValues updates the form values for Upsert with the fields the save hooks changed,
+// given the object's values before and after the hooks
+func hookedValues(values map[string][]string, before, after map[string]string) {
+	for field, value := range after {
+		if before[field] == value {
+			continue
+		}
+		// the whole field replaces any values submitted within it
+		for key := range values {
+			if strings.HasPrefix(key, field+".") {
+				delete(values, key)
+			}
+		}
+		values[field] = []string{value}
+	}
+}
+
+func (m *ModelAdmin) postSave(ctx context.Context, pk string, obj interface{}, created bool) {
+	if h, ok := obj.(hooks.PostSave); ok {
+		h.PostSave(ctx)
+	}
+	if h, ok := m.hooks().(hooks.PostSaveObject); ok {
+		h.PostSaveObject(ctx, pk, obj)
+	}
+	if !created {
+		return
+	}
+	if h, ok := obj.(hooks.PostCreate); ok {
+		h.PostCreate(ctx)
+	}
+	if h, ok := m.hooks().(hooks.PostCreateObject); ok {
+		h.PostCreateObject(ctx, pk, obj)
+	}
+}
+
+func (m *ModelAdmin) preDelete(ctx context.Context, pk string, obj interface{}) error {
+	if h, ok := obj.(hooks.PreDelete); ok {
+		if err := h.PreDelete(ctx); err != nil {
+			return vetoed(err)
+		}
+	}
+	if h, ok := m.hooks().(hooks.PreDeleteObject); ok {
+		return vetoed(h.PreDeleteObject(ctx, pk, obj))
+	}
+	return nil
+}
+
+func (m *ModelAdmin) postDelete(ctx context.Context, pk string, obj interface{}) {
+	if h, ok := obj.(hooks.PostDelete); ok {
+		h.PostDelete(ctx)
+	}
+	if h, ok := m.hooks().(hooks.PostDeleteObject); ok {
+		h.PostDeleteObject(ctx, pk, obj)
+	}
+}
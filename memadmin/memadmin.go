@@ -0,0 +1,350 @@
+// Package memadmin provides an in-memory godmin accessor, for prototypes, demos and tests.
+package memadmin
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpitfield/godmin"
+)
+
+// Store is a godmin ContextAccessor and FilterAccessor keeping structs of one type in memory,
+// safe for concurrent use. Objects are keyed by their primary key field, formatted with fmt.Sprint;
+// objects added with a zero integer or string primary key are numbered after the highest one so far.
+// Lists are sorted on any fields by their values, then by primary key.
+// List and Count only return objects within the request's godmin.Scope.
+type Store struct {
+	mu      sync.RWMutex
+	typ     reflect.Type
+	pkField string
+	objects map[string]reflect.Value
+	lastPK  int64
+}
+
+// New returns an empty Store of the prototype's struct type, keyed by the named field.
+func New(prototype interface{}, pkField string) *Store {
+	typ := reflect.TypeOf(prototype)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("memadmin: %T isn't a struct", prototype))
+	}
+	if _, exists := typ.FieldByName(pkField); !exists {
+		panic(fmt.Sprintf("memadmin: %v has no field %v", typ, pkField))
+	}
+	return &Store{typ: typ, pkField: pkField, objects: make(map[string]reflect.Value)}
+}
+
+// Put adds the objects, or replaces those with the same primary keys, e.g. to load fixtures.
+// It returns the primary key of the last object.
+func (s *Store) Put(objs ...interface{}) (pk string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, obj := range objs {
+		v := reflect.Indirect(reflect.ValueOf(obj))
+		if v.Type() != s.typ {
+			return "", fmt.Errorf("memadmin: can't store a %T with %v", obj, s.typ)
+		}
+		if pk, err = s.put(v); err != nil {
+			return "", err
+		}
+	}
+	return pk, nil
+}
+
+// store a copy of v, numbering it if its primary key is zero
+func (s *Store) put(v reflect.Value) (string, error) {
+	obj := reflect.New(s.typ).Elem()
+	obj.Set(v)
+	pkValue := obj.FieldByName(s.pkField)
+	if pkValue.IsZero() {
+		s.lastPK++
+		switch pkValue.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			pkValue.SetInt(s.lastPK)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			pkValue.SetUint(uint64(s.lastPK))
+		case reflect.String:
+			pkValue.SetString(strconv.FormatInt(s.lastPK, 10))
+		default:
+			return "", fmt.Errorf("memadmin: can't number a %v primary key", pkValue.Type())
+		}
+	}
+	pk := fmt.Sprint(pkValue.Interface())
+	if n, err := strconv.ParseInt(pk, 10, 64); err == nil && n > s.lastPK {
+		s.lastPK = n
+	}
+	s.objects[pk] = obj
+	return pk, nil
+}
+
+func (s *Store) Prototype() interface{} {
+	return reflect.New(s.typ).Elem().Interface()
+}
+
+func (s *Store) Get(ctx context.Context, pk string) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, exists := s.objects[pk]
+	if !exists || !inScope(ctx, obj) {
+		return nil, godmin.ErrNotFound
+	}
+	return obj.Interface(), nil
+}
+
+func (s *Store) List(ctx context.Context, count, page int, order []godmin.Order) (interface{}, error) {
+	return s.ListFiltered(ctx, count, page, order, nil)
+}
+
+func (s *Store) Count(ctx context.Context) (int, error) {
+	return s.CountFiltered(ctx, nil)
+}
+
+func (s *Store) ListFiltered(ctx context.Context, count, page int, order []godmin.Order, conditions []godmin.Condition) (interface{}, error) {
+	matches, err := s.matching(ctx, conditions, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.page(matches, count, page, order)
+}
+
+func (s *Store) CountFiltered(ctx context.Context, conditions []godmin.Condition) (int, error) {
+	matches, err := s.matching(ctx, conditions, nil)
+	return len(matches), err
+}
+
+// Upsert decodes the form values, keyed by the identifiers godmin.Marshal produces, onto the stored
+// object, or a new one if pk is "", and stores the result. Setting the primary key to that of
+// another object fails with godmin.ErrConflict, and clearing it keeps the stored one.
+func (s *Store) Upsert(ctx context.Context, pk string, values map[string][]string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	base := reflect.New(s.typ).Elem()
+	if pk != "" {
+		obj, exists := s.objects[pk]
+		if !exists || !inScope(ctx, obj) {
+			return "", godmin.ErrNotFound
+		}
+		base = obj
+	}
+	obj, err := godmin.Decode(values, base.Interface())
+	if err != nil {
+		return "", err
+	}
+	v := reflect.ValueOf(obj).Elem()
+	pkValue := v.FieldByName(s.pkField)
+	if pkValue.IsZero() && pk != "" {
+		// a cleared primary key keeps the stored one rather than numbering a copy
+		pkValue.Set(base.FieldByName(s.pkField))
+	}
+	if !pkValue.IsZero() {
+		if newPK := fmt.Sprint(pkValue.Interface()); newPK != pk {
+			// the primary key was set or changed, and mustn't replace another object
+			if _, exists := s.objects[newPK]; exists {
+				return "", fmt.Errorf("%w: %v %v already exists", godmin.ErrConflict, s.pkField, newPK)
+			}
+			delete(s.objects, pk)
+		}
+	}
+	return s.put(v)
+}
+
+func (s *Store) DeletePK(ctx context.Context, pk string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, exists := s.objects[pk]
+	if !exists || !inScope(ctx, obj) {
+		return godmin.ErrNotFound
+	}
+	delete(s.objects, pk)
+	return nil
+}
+
+// Searcher returns a godmin Searcher matching objects with any of the fields containing the query,
+// ignoring case, or any string field if none are given. Results are within the request's Scope and list filters.
+func (s *Store) Searcher(fields ...string) *godmin.Searcher {
+	if len(fields) == 0 {
+		for i := 0; i < s.typ.NumField(); i++ {
+			if field := s.typ.Field(i); field.PkgPath == "" && field.Type.Kind() == reflect.String {
+				fields = append(fields, field.Name)
+			}
+		}
+	}
+	for _, field := range fields {
+		if _, exists := s.typ.FieldByName(field); !exists {
+			panic(fmt.Sprintf("memadmin: %v has no field %v", s.typ, field))
+		}
+	}
+	return &godmin.Searcher{
+		Placeholder: "Search " + strings.Join(fields, ", "),
+		SearchContext: func(ctx context.Context, count, page int, query string, order []godmin.Order) (interface{}, int, error) {
+			query = strings.ToLower(query)
+			search := func(obj reflect.Value) bool {
+				for _, field := range fields {
+					if strings.Contains(strings.ToLower(fmt.Sprint(obj.FieldByName(field).Interface())), query) {
+						return true
+					}
+				}
+				return false
+			}
+			matches, err := s.matching(ctx, godmin.FiltersFromContext(ctx), search)
+			if err != nil {
+				return nil, 0, err
+			}
+			results, err := s.page(matches, count, page, order)
+			return results, len(matches), err
+		},
+	}
+}
+
+// the objects within the request's scope meeting the conditions and the optional search
+func (s *Store) matching(ctx context.Context, conditions []godmin.Condition, search func(obj reflect.Value) bool) ([]reflect.Value, error) {
+	for _, condition := range conditions {
+		if _, exists := s.typ.FieldByName(condition.Field); !exists {
+			return nil, fmt.Errorf("%w: can't filter by %q", godmin.ErrBadRequest, condition.Field)
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matches []reflect.Value
+	for _, obj := range s.objects {
+		if !inScope(ctx, obj) || (search != nil && !search(obj)) {
+			continue
+		}
+		meets := true
+		for _, condition := range conditions {
+			if meets = meetsCondition(obj.FieldByName(condition.Field), condition); !meets {
+				break
+			}
+		}
+		if meets {
+			matches = append(matches, obj)
+		}
+	}
+	return matches, nil
+}
+
+// sort the objects and return a page of them as a slice of the struct type
+func (s *Store) page(objs []reflect.Value, count, page int, order []godmin.Order) (interface{}, error) {
+	for _, o := range order {
+		if _, exists := s.typ.FieldByName(o.FieldName); !exists {
+			return nil, fmt.Errorf("%w: can't sort by %q", godmin.ErrBadRequest, o.FieldName)
+		}
+	}
+	order = append(order[:len(order):len(order)], godmin.Order{FieldName: s.pkField, Ascending: true})
+	sort.SliceStable(objs, func(i, j int) bool {
+		for _, o := range order {
+			c := compare(objs[i].FieldByName(o.FieldName), objs[j].FieldByName(o.FieldName))
+			if c != 0 {
+				return c < 0 == o.Ascending
+			}
+		}
+		return false
+	})
+	results := reflect.MakeSlice(reflect.SliceOf(s.typ), 0, len(objs))
+	if page < 0 {
+		page = 0
+	}
+	start := page * count
+	for i := start; i < len(objs) && (count <= 0 || i < start+count); i++ {
+		results = reflect.Append(results, objs[i])
+	}
+	return results.Interface(), nil
+}
+
+// whether every scoped field of obj has the request's Scope value, compared as by godmin
+func inScope(ctx context.Context, obj reflect.Value) bool {
+	for field, value := range godmin.ScopeFromContext(ctx) {
+		f := reflect.Indirect(obj.FieldByName(field))
+		if !f.IsValid() || fmt.Sprint(f.Interface()) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func meetsCondition(field reflect.Value, condition godmin.Condition) bool {
+	switch condition.Op {
+	case godmin.OpIsNull:
+		return field.IsZero()
+	case godmin.OpNotNull:
+		return !field.IsZero()
+	}
+	c := compare(field, reflect.ValueOf(condition.Value))
+	switch condition.Op {
+	case godmin.OpEqual:
+		return c == 0
+	case godmin.OpGreaterOrEqual:
+		return c >= 0
+	case godmin.OpLess:
+		return c < 0
+	case godmin.OpLessOrEqual:
+		return c <= 0
+	}
+	return false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// compare two values as numbers or times if they both are, otherwise as formatted by fmt, nil pointers first
+func compare(a, b reflect.Value) int {
+	for a.Kind() == reflect.Ptr || a.Kind() == reflect.Interface {
+		if a.IsNil() {
+			if b.Kind() == reflect.Ptr && b.IsNil() {
+				return 0
+			}
+			return -1
+		}
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Ptr || b.Kind() == reflect.Interface {
+		if b.IsNil() {
+			return 1
+		}
+		b = b.Elem()
+	}
+	if !a.IsValid() || !b.IsValid() {
+		return strings.Compare(fmt.Sprint(a.IsValid()), fmt.Sprint(b.IsValid()))
+	}
+	if af, ok := number(a); ok {
+		if bf, ok := number(b); ok {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			}
+			return 0
+		}
+	}
+	if a.Type() == timeType && b.Type() == timeType {
+		at, bt := a.Interface().(time.Time), b.Interface().(time.Time)
+		switch {
+		case at.Before(bt):
+			return -1
+		case at.After(bt):
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+}
+
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
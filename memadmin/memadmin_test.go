@@ -0,0 +1,201 @@
+package memadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpitfield/godmin"
+)
+
+type Address struct {
+	City string
+}
+
+type Customer struct {
+	ID      int
+	Name    string
+	Seats   int
+	Signed  time.Time
+	Active  bool
+	Address Address
+	Tags    []string
+}
+
+func names(t *testing.T, results interface{}, err error) []string {
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, c := range results.([]Customer) {
+		out = append(out, c.Name)
+	}
+	return out
+}
+
+func testStore(t *testing.T) *Store {
+	s := New(Customer{}, "ID")
+	signed := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	_, err := s.Put(
+		Customer{Name: "Acme", Seats: 50, Signed: signed, Active: true, Address: Address{"Springfield"}},
+		Customer{Name: "Globex", Seats: 5, Signed: signed.AddDate(0, 1, 0), Address: Address{"Cypress Creek"}},
+		&Customer{ID: 10, Name: "Initech", Seats: 50, Signed: signed.AddDate(0, 2, 0), Active: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	if pk, _ := s.Put(Customer{Name: "Hooli"}); pk != "11" {
+		t.Errorf("numbered after the highest pk got %v", pk)
+	}
+	s.DeletePK(ctx, "11")
+
+	obj, err := s.Get(ctx, "2")
+	if err != nil || obj.(Customer).Name != "Globex" {
+		t.Errorf("got %v, %v", obj, err)
+	}
+	if _, err := s.Get(ctx, "9"); !errors.Is(err, godmin.ErrNotFound) {
+		t.Errorf("missing pk got %v", err)
+	}
+
+	order := []godmin.Order{{FieldName: "Seats"}, {FieldName: "Name", Ascending: true}}
+	results, err := s.List(ctx, 2, 0, order)
+	if got := names(t, results, err); !reflect.DeepEqual(got, []string{"Acme", "Initech"}) {
+		t.Errorf("first page got %v", got)
+	}
+	results, err = s.List(ctx, 2, 1, order)
+	if got := names(t, results, err); !reflect.DeepEqual(got, []string{"Globex"}) {
+		t.Errorf("second page got %v", got)
+	}
+	results, err = s.List(ctx, 2, -1, order)
+	if got := names(t, results, err); !reflect.DeepEqual(got, []string{"Acme", "Initech"}) {
+		t.Errorf("negative page got %v", got)
+	}
+	if _, err := s.List(ctx, 2, 0, []godmin.Order{{FieldName: "Password"}}); !errors.Is(err, godmin.ErrBadRequest) {
+		t.Errorf("unknown order got %v", err)
+	}
+
+	conditions := []godmin.Condition{
+		{Field: "Active", Op: godmin.OpEqual, Value: true},
+		{Field: "Signed", Op: godmin.OpLess, Value: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Field: "Seats", Op: godmin.OpGreaterOrEqual, Value: 50.0},
+	}
+	results, err = s.ListFiltered(ctx, 10, 0, nil, conditions)
+	if got := names(t, results, err); !reflect.DeepEqual(got, []string{"Acme"}) {
+		t.Errorf("filtered got %v", got)
+	}
+	if count, _ := s.CountFiltered(ctx, []godmin.Condition{{Field: "Address", Op: godmin.OpIsNull}}); count != 1 {
+		t.Errorf("empty addresses counted %v", count)
+	}
+
+	results, total, err := s.Searcher().SearchContext(ctx, 10, 0, "TECH", nil)
+	if got := names(t, results, err); total != 1 || !reflect.DeepEqual(got, []string{"Initech"}) {
+		t.Errorf("search got %v of %v", got, total)
+	}
+
+	// form values are keyed like Marshal's identifiers
+	if _, err := s.Upsert(ctx, "2", url.Values{"Seats": {"7"}, "Address.City": {"Springfield"}, "Tags.0": {"vip"}}); err != nil {
+		t.Fatal(err)
+	}
+	obj, _ = s.Get(ctx, "2")
+	if c := obj.(Customer); c.Seats != 7 || c.Address.City != "Springfield" || c.Name != "Globex" || !reflect.DeepEqual(c.Tags, []string{"vip"}) {
+		t.Errorf("upserted %+v", c)
+	}
+	if _, err := s.Upsert(ctx, "1", url.Values{"Seats": {"many"}}); !errors.Is(err, godmin.ErrValidation) {
+		t.Errorf("invalid value got %v", err)
+	}
+	if _, err := s.Upsert(ctx, "2", url.Values{"ID": {"10"}}); !errors.Is(err, godmin.ErrConflict) {
+		t.Errorf("changing the pk to an existing one got %v", err)
+	}
+	if _, err := s.Upsert(ctx, "", url.Values{"ID": {"1"}, "Name": {"Copy"}}); !errors.Is(err, godmin.ErrConflict) {
+		t.Errorf("adding an existing pk got %v", err)
+	}
+	if obj, _ := s.Get(ctx, "10"); obj.(Customer).Name != "Initech" {
+		t.Errorf("object was overwritten: %+v", obj)
+	}
+	if pk, err := s.Upsert(ctx, "10", url.Values{"ID": {""}, "Name": {"Initrode"}}); err != nil || pk != "10" {
+		t.Errorf("clearing the pk saved %v, %v", pk, err)
+	}
+	if n, _ := s.Count(ctx); n != 3 {
+		t.Errorf("clearing the pk left %d objects", n)
+	}
+	// deleted primary keys aren't reused
+	if pk, err := s.Upsert(ctx, "", url.Values{"Name": {"Hooli"}}); err != nil || pk != "12" {
+		t.Errorf("inserted %v, %v", pk, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Upsert(ctx, "", url.Values{"Name": {"Umbrella"}})
+			s.List(ctx, 10, 0, order)
+		}()
+	}
+	wg.Wait()
+	if count, _ := s.Count(ctx); count != 14 {
+		t.Errorf("count got %v", count)
+	}
+}
+
+type intPK struct{}
+
+func (intPK) PKString(pk interface{}) string { return fmt.Sprint(pk) }
+
+func TestAdmin(t *testing.T) {
+	s := testStore(t)
+	admin := godmin.NewAdmin()
+	ma := godmin.NewModelAdmin("customers", "ID", map[string]bool{"Name": true, "Seats": true}, nil, nil, nil, nil, intPK{}, nil, s.Searcher("Name"))
+	ma.ContextAccessor = s
+	ma.ListFilters = []godmin.ListFilter{{Field: "Active", Kind: godmin.FilterBool}}
+	ma.ScopeFunc = func(c *gin.Context) godmin.Scope {
+		if c.Query("scoped") != "" {
+			return godmin.Scope{"Seats": 50}
+		}
+		return nil
+	}
+	admin.Register(ma)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	tmpl := template.Must(template.New("admin/list.html").Parse("{{range .pks}}{{.}} {{end}}"))
+	template.Must(tmpl.New("admin/error.html").Parse("{{.error}}"))
+	r.SetHTMLTemplate(tmpl)
+	admin.Routes(r.Group("/admin"))
+
+	for query, want := range map[string]string{
+		"":                      "1 2 10 ",
+		"?o=-Seats,-Name":       "10 1 2 ",
+		"?f.Active=false":       "2 ",
+		"?q=e&o=Name":           "1 2 10 ",
+		"?q=e&f.Active=true":    "1 10 ",
+		"?scoped=1&o=-Name":     "10 1 ",
+		"?scoped=1&q=g&o=-Name": "",
+		"?page=-1":              "status 400",
+		"?page=x":               "status 400",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/customers/"+query, nil))
+		got := w.Body.String()
+		if w.Code != http.StatusOK {
+			got = fmt.Sprintf("status %d", w.Code)
+		}
+		if got != want {
+			t.Errorf("%q: got %q, want %q", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package godmin
+
+import (
+	"reflect"
+)
+
+// Fieldset is a titled group of fields in the change view, similar to Django's admin fieldsets.
+type Fieldset struct {
+	Title       string
+	Description string
+	Fields      []string
+	Collapsed   bool // render the fieldset collapsed until the user expands it
+}
+
+// declaredFields returns the prototype's field names in declaration order
+func declaredFields(proto interface{}) (fields []string) {
+	protoType := reflect.TypeOf(proto)
+	if protoType == nil {
+		return
+	}
+	if protoType.Kind() == reflect.Ptr {
+		protoType = protoType.Elem()
+	}
+	if protoType.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < protoType.NumField(); i++ {
+		fields = append(fields, protoType.Field(i).Name)
+	}
+	return
+}
+
+// orderFields returns the fields named in order, followed by the rest of the declared fields
+// in declaration order. Names in order that aren't declared are dropped.
+func orderFields(declared []string, order []string) (fields []string) {
+	isDeclared := make(map[string]bool, len(declared))
+	for _, field := range declared {
+		isDeclared[field] = true
+	}
+	seen := make(map[string]bool, len(declared))
+	for _, field := range order {
+		if isDeclared[field] && !seen[field] {
+			fields = append(fields, field)
+			seen[field] = true
+		}
+	}
+	for _, field := range declared {
+		if !seen[field] {
+			fields = append(fields, field)
+		}
+	}
+	return
+}
+
+// applyOrdering computes the ModelAdmin's change view fieldsets and list view columns
+// from its FieldOrder, ListOrder and Fieldsets.
+func applyOrdering(ma *ModelAdmin) {
+	declared := declaredFields(ma.accessor().Prototype())
+	fieldOrder := orderFields(declared, ma.FieldOrder)
+
+	ma.listOrder = nil
+	for _, field := range orderFields(declared, ma.ListOrder) {
+		if _, listed := ma.ListFields[field]; listed {
+			ma.listOrder = append(ma.listOrder, field)
+		}
+	}
+
+	if len(ma.Fieldsets) == 0 {
+		ma.fieldsets = []Fieldset{{Fields: fieldOrder}}
+		return
+	}
+	ma.fieldsets = nil
+	placed := make(map[string]bool)
+	for _, fs := range ma.Fieldsets {
+		fields := fs.Fields
+		fs.Fields = nil
+		for _, field := range fields {
+			if contains(declared, field) && !placed[field] {
+				fs.Fields = append(fs.Fields, field)
+				placed[field] = true
+			}
+		}
+		ma.fieldsets = append(ma.fieldsets, fs)
+	}
+	var rest []string
+	for _, field := range fieldOrder {
+		if !placed[field] {
+			rest = append(rest, field)
+		}
+	}
+	if len(rest) > 0 {
+		ma.fieldsets = append(ma.fieldsets, Fieldset{Fields: rest})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+// Package rbac provides role-based access control for godmin. Roles grant actions on
+// models, users are assigned roles, and an Authenticator checks every admin request
+// against them while another Authenticator takes care of logging users in.
+//
+//	policy, err := rbac.LoadFile("admin-roles.json")
+//	admin.SetAuthenticator(rbac.New(auth.New(users, secret), policy))
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpitfield/godmin"
+)
+
+// Actions that can be granted on a model, besides the Identifiers of its list actions.
+// List actions whose Identifiers are one of these names or "*" are granted as "action:<Identifier>".
+const (
+	View   = "view"
+	Add    = "add"
+	Change = "change"
+	Delete = "delete"
+	All    = "*" // grants every action, or as a model name, grants the actions on every model
+)
+
+// the godmin privilege names for the actions above
+var privileges = map[string]string{
+	godmin.PrivilegeView:   View,
+	godmin.PrivilegeAdd:    Add,
+	godmin.PrivilegeChange: Change,
+	godmin.PrivilegeDelete: Delete,
+}
+
+// the name action is granted by: the names above for godmin's privileges, and the Identifier
+// for list actions unless it's taken by one of those
+func grantName(action string) string {
+	if name, exists := privileges[action]; exists {
+		return name
+	}
+	identifier := strings.TrimPrefix(action, godmin.PrivilegeActionPrefix)
+	switch identifier {
+	case View, Add, Change, Delete, All:
+		return action
+	}
+	return identifier
+}
+
+// Grants maps model names to the actions granted on them.
+type Grants map[string][]string
+
+// allows reports whether the grants include action on model.
+func (g Grants) allows(model string, action string) bool {
+	for _, name := range []string{model, All} {
+		for _, granted := range g[name] {
+			if granted == action || granted == All {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Store provides the roles assigned to users and the grants of each role,
+// e.g. from a database.
+type Store interface {
+	UserRoles(ctx context.Context, username string) (roles []string, err error)
+	RoleGrants(ctx context.Context, role string) (grants Grants, err error)
+}
+
+// Policy is a Store kept in memory, usually loaded from a JSON config file:
+//
+//	{
+//		"roles": {
+//			"support": {"Customer": ["view", "change"], "Order": ["view", "refund"]},
+//			"superuser": {"*": ["*"]}
+//		},
+//		"users": {"alice": ["superuser"], "bob": ["support"]}
+//	}
+type Policy struct {
+	mu    sync.RWMutex
+	roles map[string]Grants
+	users map[string][]string
+}
+
+type policyFile struct {
+	Roles map[string]Grants   `json:"roles"`
+	Users map[string][]string `json:"users"`
+}
+
+// NewPolicy returns an empty Policy.
+func NewPolicy() *Policy {
+	return &Policy{roles: make(map[string]Grants), users: make(map[string][]string)}
+}
+
+// Load reads a Policy from its JSON representation.
+func Load(r io.Reader) (*Policy, error) {
+	var f policyFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	p := NewPolicy()
+	for role, grants := range f.Roles {
+		p.SetRole(role, grants)
+	}
+	for username, roles := range f.Users {
+		p.Assign(username, roles...)
+	}
+	return p, nil
+}
+
+// LoadFile reads a Policy from a JSON config file.
+func LoadFile(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// SetRole defines or replaces a role.
+func (p *Policy) SetRole(role string, grants Grants) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.roles[role] = grants
+}
+
+// Assign sets the roles of a user, replacing any they had.
+func (p *Policy) Assign(username string, roles ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users[username] = roles
+}
+
+func (p *Policy) UserRoles(ctx context.Context, username string) ([]string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.users[username], nil
+}
+
+func (p *Policy) RoleGrants(ctx context.Context, role string) (Grants, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.roles[role], nil
+}
+
+// Authenticator is a godmin.Authenticator that leaves logging in to the wrapped Authenticator
+// and checks privileges against the roles in Store. Users without a role can't use the admin.
+type Authenticator struct {
+	godmin.Authenticator
+	Store Store
+}
+
+// New returns an Authenticator logging users in with login and checking their privileges against store.
+func New(login godmin.Authenticator, store Store) *Authenticator {
+	return &Authenticator{Authenticator: login, Store: store}
+}
+
+// HasPrivilege reports whether one of the user's roles grants action on the collection.
+// Any user with a role may see the admin home page.
+func (a *Authenticator) HasPrivilege(c *gin.Context, collection string, action string, ids []string) (ok bool) {
+	username := c.GetString("username")
+	if username == "" {
+		return false
+	}
+	roles, err := a.Store.UserRoles(c.Request.Context(), username)
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+	if collection == "" {
+		return len(roles) > 0
+	}
+	action = grantName(action)
+	for _, role := range roles {
+		grants, err := a.Store.RoleGrants(c.Request.Context(), role)
+		if err != nil {
+			c.Error(err)
+			return false
+		}
+		if grants.allows(collection, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Routes mounts the wrapped Authenticator's pages, if it has any.
+func (a *Authenticator) Routes(admin *godmin.Admin, r *gin.RouterGroup) {
+	if ra, ok := a.Authenticator.(godmin.RouteAuthenticator); ok {
+		ra.Routes(admin, r)
+	}
+}
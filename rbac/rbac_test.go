@@ -0,0 +1,64 @@
+package rbac
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpitfield/godmin"
+)
+
+const policyJSON = `{
+	"roles": {
+		"support": {"Customer": ["view", "change"], "Order": ["view", "refund"], "Invoice": ["action:delete"]},
+		"superuser": {"*": ["*"]}
+	},
+	"users": {"alice": ["superuser"], "bob": ["support"], "carol": []}
+}`
+
+type loggedIn string
+
+func (l loggedIn) IsAdmin(c *gin.Context) bool {
+	c.Set("username", string(l))
+	return true
+}
+func (l loggedIn) HasPrivilege(c *gin.Context, collection string, action string, ids []string) bool {
+	return true
+}
+
+func TestHasPrivilege(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy, err := Load(strings.NewReader(policyJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		username, collection, action string
+		ok                           bool
+	}{
+		{"alice", "Customer", godmin.PrivilegeChange, true},
+		{"alice", "Invoice", godmin.PrivilegeDelete, true},
+		{"bob", "", godmin.PrivilegeView, true},
+		{"bob", "Customer", godmin.PrivilegeView, true},
+		{"bob", "Customer", godmin.PrivilegeChange, true},
+		{"bob", "Customer", godmin.PrivilegeAdd, false},
+		{"bob", "Order", godmin.ActionPrivilege("refund"), true},
+		{"bob", "Order", godmin.ActionPrivilege("view"), false},
+		{"bob", "Invoice", godmin.ActionPrivilege("delete"), true},
+		{"bob", "Invoice", godmin.PrivilegeDelete, false},
+		{"bob", "Order", godmin.PrivilegeChange, false},
+		{"bob", "Invoice", godmin.PrivilegeView, false},
+		{"carol", "", godmin.PrivilegeView, false},
+		{"dave", "Customer", godmin.PrivilegeView, false},
+	}
+	for _, tc := range cases {
+		a := New(loggedIn(tc.username), policy)
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/admin/", nil)
+		a.IsAdmin(c)
+		if ok := a.HasPrivilege(c, tc.collection, tc.action, nil); ok != tc.ok {
+			t.Errorf("%v %v %q: got %v, want %v", tc.username, tc.action, tc.collection, ok, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package godmin
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scope restricts the objects of a model an admin user can reach to those whose fields
+// have the given values, e.g. Scope{"TenantID": 42}.
+type Scope map[string]interface{}
+
+type scopeContextKey struct{}
+
+// ScopeFromContext returns the Scope of the request, as produced by the ModelAdmin's ScopeFunc.
+// Accessors and Searchers must only list, count and return objects within it.
+func ScopeFromContext(ctx context.Context) Scope {
+	scope, _ := ctx.Value(scopeContextKey{}).(Scope)
+	return scope
+}
+
+// build the context handed to the ModelAdmin's accessor, with the user's Scope if it has a ScopeFunc
+func (m *ModelAdmin) requestContext(c *gin.Context) context.Context {
+	ctx := requestContext(c)
+	if m.ScopeFunc == nil {
+		return ctx
+	}
+	scope := m.ScopeFunc(c)
+	if scope == nil {
+		scope = Scope{} // an empty scope rather than none, so accessors can tell it was applied
+	}
+	return context.WithValue(ctx, scopeContextKey{}, scope)
+}
+
+// reports whether every scoped field of obj has the scope's value
+func (s Scope) contains(obj interface{}) bool {
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	if v.Kind() != reflect.Struct {
+		return len(s) == 0
+	}
+	for field, value := range s {
+		f := reflect.Indirect(v.FieldByName(field))
+		if !f.IsValid() || fmt.Sprint(f.Interface()) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// get the object with the pk, treating objects outside the request's Scope as not found
+func (m *ModelAdmin) get(ctx context.Context, pk string) (obj interface{}, err error) {
+	obj, err = m.accessor().Get(ctx, pk)
+	if err != nil {
+		return nil, err
+	}
+	if !ScopeFromContext(ctx).contains(obj) {
+		return nil, ErrNotFound
+	}
+	return obj, nil
+}
+
+// set the scoped fields in values submitted from a form, so objects can't be saved outside the Scope
+func (s Scope) apply(values map[string][]string) {
+	for field, value := range s {
+		values[field] = []string{fmt.Sprint(value)}
+	}
+}
@@ -0,0 +1,505 @@
+// Package sqladmin provides a godmin accessor for structs stored in a database/sql table.
+package sqladmin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gpitfield/godmin"
+	"github.com/gpitfield/godmin/sqlparam"
+)
+
+// tagName is the struct tag naming a field's column, e.g.
+//
+//	ID      int64     `db:"id,pk"`
+//	Name    string    `db:"name"`
+//	Created time.Time `db:"created_at"`
+//	Notes   string    `db:"-"`
+//
+// Untagged exported fields are stored in the column of their lowercased name, and fields tagged "-" aren't stored.
+const tagName = "db"
+
+// Table is a godmin ContextAccessor, FilterAccessor and ContextSaver for a struct type stored in a
+// database table with a column per field. The primary key is the field tagged pk, or else the one named ID.
+// Objects are saved with a zero primary key left out of the INSERT so the database generates it.
+// NULLs are read as the field's zero value unless the field is a pointer.
+// List and Count only return objects within the request's godmin.Scope.
+type Table struct {
+	DB          *sql.DB
+	Name        string
+	Placeholder func(n int) string // e.g. sqlparam.Dollar for PostgreSQL, defaults to sqlparam.Question
+	// read generated primary keys with INSERT ... RETURNING, as PostgreSQL requires, rather than LastInsertId
+	Returning bool
+	typ       reflect.Type
+	columns   []column
+	pk        column
+}
+
+type column struct {
+	field string
+	name  string
+	index int // of the struct field
+	kind  reflect.Kind
+}
+
+// New returns a Table of the prototype's struct type stored in the named table, using "?" query parameters.
+func New(db *sql.DB, name string, prototype interface{}) (*Table, error) {
+	typ := reflect.TypeOf(prototype)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sqladmin: %T isn't a struct", prototype)
+	}
+	t := &Table{DB: db, Name: name, Placeholder: sqlparam.Question, typ: typ}
+	pk := -1
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := strings.Split(field.Tag.Get(tagName), ",")
+		if field.PkgPath != "" || tag[0] == "-" {
+			continue
+		}
+		col := column{field: field.Name, name: tag[0], index: i, kind: field.Type.Kind()}
+		if col.name == "" {
+			col.name = strings.ToLower(field.Name)
+		}
+		for _, option := range tag[1:] {
+			if option == "pk" {
+				pk = len(t.columns)
+			}
+		}
+		if pk < 0 && field.Name == "ID" {
+			pk = len(t.columns)
+		}
+		t.columns = append(t.columns, col)
+	}
+	if pk < 0 {
+		return nil, fmt.Errorf("sqladmin: %v has no primary key field", typ)
+	}
+	t.pk = t.columns[pk]
+	return t, nil
+}
+
+// MustNew is like New but panics on errors, for registering ModelAdmins.
+func MustNew(db *sql.DB, name string, prototype interface{}) *Table {
+	t, err := New(db, name, prototype)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// PKField is the name of the primary key field, for the ModelAdmin's PKFieldName.
+func (t *Table) PKField() string {
+	return t.pk.field
+}
+
+// Columns maps the fields to their columns, for the ModelAdmin's FieldColumns.
+func (t *Table) Columns() map[string]string {
+	columns := make(map[string]string, len(t.columns))
+	for _, col := range t.columns {
+		columns[col.field] = col.name
+	}
+	return columns
+}
+
+// find a column by its field or column name
+func (t *Table) column(name string) (column, bool) {
+	for _, col := range t.columns {
+		if col.field == name || col.name == name {
+			return col, true
+		}
+	}
+	return column{}, false
+}
+
+// query builds a statement's WHERE clause and arguments
+type query struct {
+	t     *Table
+	where []string
+	args  []interface{}
+}
+
+// add an argument, returning its placeholder
+func (q *query) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return q.t.Placeholder(len(q.args))
+}
+
+func (q *query) condition(condition godmin.Condition) error {
+	col, exists := q.t.column(condition.Field)
+	if !exists {
+		return fmt.Errorf("%w: can't filter by %q", godmin.ErrBadRequest, condition.Field)
+	}
+	var clause string
+	switch condition.Op {
+	case godmin.OpEqual:
+		clause = col.name + " = " + q.arg(condition.Value)
+	case godmin.OpGreaterOrEqual:
+		clause = col.name + " >= " + q.arg(condition.Value)
+	case godmin.OpLess:
+		clause = col.name + " < " + q.arg(condition.Value)
+	case godmin.OpLessOrEqual:
+		clause = col.name + " <= " + q.arg(condition.Value)
+	case godmin.OpIsNull:
+		clause = col.name + " IS NULL"
+		if col.kind == reflect.String {
+			clause = fmt.Sprintf("(%s IS NULL OR %s = '')", col.name, col.name)
+		}
+	case godmin.OpNotNull:
+		clause = col.name + " IS NOT NULL"
+		if col.kind == reflect.String {
+			clause = fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", col.name, col.name)
+		}
+	default:
+		return fmt.Errorf("sqladmin: unsupported operator %q", condition.Op)
+	}
+	q.where = append(q.where, clause)
+	return nil
+}
+
+// restrict the query to the request's scope and the conditions
+func (q *query) filter(ctx context.Context, conditions []godmin.Condition) error {
+	scope := godmin.ScopeFromContext(ctx)
+	fields := make([]string, 0, len(scope))
+	for field := range scope {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		conditions = append(conditions, godmin.Condition{Field: field, Op: godmin.OpEqual, Value: scope[field]})
+	}
+	for _, condition := range conditions {
+		if err := q.condition(condition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *query) String() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+func (t *Table) selectColumns() string {
+	names := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		names[i] = col.name
+	}
+	return fmt.Sprintf("SELECT %s FROM %s", strings.Join(names, ", "), t.Name)
+}
+
+// an ORDER BY clause, only naming known columns
+func (t *Table) orderBy(order []godmin.Order) (string, error) {
+	if len(order) == 0 {
+		return "", nil
+	}
+	terms := make([]string, len(order))
+	for i, o := range order {
+		col, exists := t.column(o.FieldName)
+		if !exists {
+			return "", fmt.Errorf("%w: can't sort by %q", godmin.ErrBadRequest, o.FieldName)
+		}
+		terms[i] = col.name
+		if !o.Ascending {
+			terms[i] += " DESC"
+		}
+	}
+	return " ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+// scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan a row into a new struct, reading NULLs into non-pointer fields as zero values
+func (t *Table) scan(row scanner) (reflect.Value, error) {
+	obj := reflect.New(t.typ).Elem()
+	dest := make([]interface{}, len(t.columns))
+	for i, col := range t.columns {
+		field := obj.Field(col.index)
+		if field.Kind() == reflect.Ptr {
+			dest[i] = field.Addr().Interface()
+		} else {
+			dest[i] = reflect.New(reflect.PtrTo(field.Type())).Interface()
+		}
+	}
+	if err := row.Scan(dest...); err != nil {
+		return obj, err
+	}
+	for i, col := range t.columns {
+		field := obj.Field(col.index)
+		if field.Kind() != reflect.Ptr {
+			if ptr := reflect.ValueOf(dest[i]).Elem(); !ptr.IsNil() {
+				field.Set(ptr.Elem())
+			}
+		}
+	}
+	return obj, nil
+}
+
+// convert a primary key from its string form to the type of the primary key field
+func (t *Table) pkArg(pk string) (interface{}, error) {
+	switch t.pk.kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(pk, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", godmin.ErrInvalidPK, pk)
+		}
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(pk, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", godmin.ErrInvalidPK, pk)
+		}
+		return n, nil
+	}
+	return pk, nil
+}
+
+func (t *Table) Prototype() interface{} {
+	return reflect.New(t.typ).Elem().Interface()
+}
+
+func (t *Table) Get(ctx context.Context, pk string) (interface{}, error) {
+	id, err := t.pkArg(pk)
+	if err != nil {
+		return nil, err
+	}
+	q := &query{t: t}
+	q.where = append(q.where, t.pk.name+" = "+q.arg(id))
+	if err := q.filter(ctx, nil); err != nil {
+		return nil, err
+	}
+	obj, err := t.scan(t.DB.QueryRowContext(ctx, t.selectColumns()+q.String(), q.args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, godmin.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return obj.Interface(), nil
+}
+
+func (t *Table) List(ctx context.Context, count, page int, order []godmin.Order) (interface{}, error) {
+	return t.ListFiltered(ctx, count, page, order, nil)
+}
+
+func (t *Table) Count(ctx context.Context) (int, error) {
+	return t.CountFiltered(ctx, nil)
+}
+
+func (t *Table) ListFiltered(ctx context.Context, count, page int, order []godmin.Order, conditions []godmin.Condition) (interface{}, error) {
+	q := &query{t: t}
+	if err := q.filter(ctx, conditions); err != nil {
+		return nil, err
+	}
+	return t.list(ctx, q, count, page, order)
+}
+
+func (t *Table) CountFiltered(ctx context.Context, conditions []godmin.Condition) (int, error) {
+	q := &query{t: t}
+	if err := q.filter(ctx, conditions); err != nil {
+		return 0, err
+	}
+	return t.count(ctx, q)
+}
+
+// a page of the objects matching the query, as a slice of the struct type
+func (t *Table) list(ctx context.Context, q *query, count, page int, order []godmin.Order) (interface{}, error) {
+	orderBy, err := t.orderBy(order)
+	if err != nil {
+		return nil, err
+	}
+	stmt := t.selectColumns() + q.String() + orderBy
+	if page < 0 {
+		page = 0
+	}
+	if count > 0 {
+		stmt += fmt.Sprintf(" LIMIT %d OFFSET %d", count, page*count)
+	}
+	rows, err := t.DB.QueryContext(ctx, stmt, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := reflect.MakeSlice(reflect.SliceOf(t.typ), 0, count)
+	for rows.Next() {
+		obj, err := t.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = reflect.Append(results, obj)
+	}
+	return results.Interface(), rows.Err()
+}
+
+func (t *Table) count(ctx context.Context, q *query) (count int, err error) {
+	err = t.DB.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", t.Name)+q.String(), q.args...).Scan(&count)
+	return count, err
+}
+
+// Upsert decodes the form values onto the stored object, or a new one if pk is "", and saves it.
+func (t *Table) Upsert(ctx context.Context, pk string, values map[string][]string) (string, error) {
+	base := t.Prototype()
+	if pk != "" {
+		var err error
+		if base, err = t.Get(ctx, pk); err != nil {
+			return "", err
+		}
+	}
+	obj, err := godmin.Decode(values, base)
+	if err != nil {
+		return "", err
+	}
+	return t.Save(ctx, pk, obj)
+}
+
+// Save INSERTs the object if pk is "", otherwise UPDATEs the row with the pk,
+// returning godmin.ErrNotFound if there's no such row.
+func (t *Table) Save(ctx context.Context, pk string, obj interface{}) (string, error) {
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	if v.Type() != t.typ {
+		return "", fmt.Errorf("sqladmin: can't save a %T in %v", obj, t.Name)
+	}
+	if pk == "" {
+		return t.insert(ctx, v)
+	}
+	id, err := t.pkArg(pk)
+	if err != nil {
+		return "", err
+	}
+	q := &query{t: t}
+	var set []string
+	for _, col := range t.columns {
+		if col != t.pk {
+			set = append(set, col.name+" = "+q.arg(v.Field(col.index).Interface()))
+		}
+	}
+	q.where = append(q.where, t.pk.name+" = "+q.arg(id))
+	if len(set) > 0 {
+		stmt := fmt.Sprintf("UPDATE %s SET %s", t.Name, strings.Join(set, ", ")) + q.String()
+		result, err := t.DB.ExecContext(ctx, stmt, q.args...)
+		if err != nil {
+			return "", err
+		}
+		if n, err := result.RowsAffected(); err != nil || n > 0 {
+			return pk, nil
+		}
+	}
+	// nothing was updated, which MySQL also reports for rows that already had the values
+	if _, err := t.Get(ctx, pk); err != nil {
+		return "", err
+	}
+	return pk, nil
+}
+
+func (t *Table) insert(ctx context.Context, v reflect.Value) (string, error) {
+	q := &query{t: t}
+	var names, placeholders []string
+	pkField := v.Field(t.pk.index)
+	for _, col := range t.columns {
+		field := v.Field(col.index)
+		if col == t.pk && field.IsZero() {
+			continue // generated by the database
+		}
+		names = append(names, col.name)
+		placeholders = append(placeholders, q.arg(field.Interface()))
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.Name, strings.Join(names, ", "), strings.Join(placeholders, ", "))
+	if t.Returning {
+		err := t.DB.QueryRowContext(ctx, stmt+" RETURNING "+t.pk.name, q.args...).Scan(pkField.Addr().Interface())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprint(pkField.Interface()), nil
+	}
+	result, err := t.DB.ExecContext(ctx, stmt, q.args...)
+	if err != nil {
+		return "", err
+	}
+	if pkField.IsZero() {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return "", err
+		}
+		switch pkField.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			pkField.SetInt(id)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			pkField.SetUint(uint64(id))
+		default:
+			return fmt.Sprint(id), nil
+		}
+	}
+	return fmt.Sprint(pkField.Interface()), nil
+}
+
+// DeletePK deletes the row with the pk within the request's godmin.Scope,
+// returning godmin.ErrNotFound if there's no such row.
+func (t *Table) DeletePK(ctx context.Context, pk string) error {
+	id, err := t.pkArg(pk)
+	if err != nil {
+		return err
+	}
+	q := &query{t: t}
+	q.where = append(q.where, t.pk.name+" = "+q.arg(id))
+	if err := q.filter(ctx, nil); err != nil {
+		return err
+	}
+	result, err := t.DB.ExecContext(ctx, "DELETE FROM "+t.Name+q.String(), q.args...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return godmin.ErrNotFound
+	}
+	return nil
+}
+
+// likeEscaper escapes LIKE wildcards with "!", which unlike a backslash needs no escaping in MySQL's string literals
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// Searcher returns a godmin Searcher matching objects with any of the fields containing the query,
+// within the request's Scope and list filters. It panics if the table has no such field.
+func (t *Table) Searcher(fields ...string) *godmin.Searcher {
+	columns := make([]column, 0, len(fields))
+	for _, field := range fields {
+		col, exists := t.column(field)
+		if !exists {
+			panic(fmt.Sprintf("sqladmin: %v has no field %q", t.Name, field))
+		}
+		columns = append(columns, col)
+	}
+	return &godmin.Searcher{
+		Placeholder: "Search " + strings.Join(fields, ", "),
+		SearchContext: func(ctx context.Context, count, page int, search string, order []godmin.Order) (interface{}, int, error) {
+			q := &query{t: t}
+			var like []string
+			for _, col := range columns {
+				like = append(like, col.name+" LIKE "+q.arg("%"+likeEscaper.Replace(search)+"%")+" ESCAPE '!'")
+			}
+			if len(like) > 0 {
+				q.where = append(q.where, "("+strings.Join(like, " OR ")+")")
+			}
+			if err := q.filter(ctx, godmin.FiltersFromContext(ctx)); err != nil {
+				return nil, 0, err
+			}
+			total, err := t.count(ctx, q)
+			if err != nil {
+				return nil, 0, err
+			}
+			results, err := t.list(ctx, q, count, page, order)
+			return results, total, err
+		},
+	}
+}
@@ -0,0 +1,232 @@
+package sqladmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpitfield/godmin"
+	"github.com/gpitfield/godmin/internal/sqltest"
+)
+
+type Customer struct {
+	ID      int64     `db:"id,pk"`
+	Name    string    `db:"name"`
+	Email   string    // stored in email
+	Seats   int       `db:"seats"`
+	Signed  time.Time `db:"signed_at"`
+	Note    *string   `db:"note"`
+	Tenant  string    `db:"tenant"`
+	Derived string    `db:"-"`
+}
+
+func testTable(t *testing.T) *Table {
+	db := sqltest.Open(t, `CREATE TABLE customers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		email TEXT,
+		seats INTEGER NOT NULL,
+		signed_at TIMESTAMP NOT NULL,
+		note TEXT,
+		tenant TEXT NOT NULL
+	)`)
+	return MustNew(db, "customers", Customer{})
+}
+
+func names(t *testing.T, results interface{}, err error) []string {
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, c := range results.([]Customer) {
+		out = append(out, c.Name)
+	}
+	return out
+}
+
+func TestTable(t *testing.T) {
+	table := testTable(t)
+	ctx := context.Background()
+	if table.PKField() != "ID" || table.Columns()["Email"] != "email" || table.Columns()["Signed"] != "signed_at" {
+		t.Errorf("got pk %v, columns %v", table.PKField(), table.Columns())
+	}
+	signed := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	note := "VIP"
+	for i, c := range []Customer{
+		{Name: "Acme", Email: "ops@acme.test", Seats: 50, Signed: signed, Note: &note, Tenant: "a"},
+		{Name: "Globex", Seats: 5, Signed: signed.AddDate(0, 1, 0), Tenant: "a"},
+		{Name: "Initech", Email: "bill@initech.test", Seats: 500, Signed: signed.AddDate(0, 2, 0), Tenant: "b"},
+	} {
+		pk, err := table.Save(ctx, "", &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"1", "2", "3"}[i]; pk != want {
+			t.Errorf("saved %v with pk %v, want %v", c.Name, pk, want)
+		}
+	}
+
+	obj, err := table.Get(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := obj.(Customer); c.Name != "Acme" || c.Note == nil || *c.Note != "VIP" || !c.Signed.Equal(signed) {
+		t.Errorf("got %+v", c)
+	}
+	if obj, _ = table.Get(ctx, "2"); obj.(Customer).Email != "" || obj.(Customer).Note != nil {
+		t.Errorf("NULLs read as %+v", obj)
+	}
+	if _, err := table.Get(ctx, "9"); !errors.Is(err, godmin.ErrNotFound) {
+		t.Errorf("missing pk got %v", err)
+	}
+	if _, err := table.Get(ctx, "x"); !errors.Is(err, godmin.ErrInvalidPK) {
+		t.Errorf("invalid pk got %v", err)
+	}
+
+	results, err := table.List(ctx, 2, 0, []godmin.Order{{FieldName: "tenant"}, {FieldName: "Seats", Ascending: true}})
+	if got := names(t, results, err); !reflect.DeepEqual(got, []string{"Initech", "Globex"}) {
+		t.Errorf("first page got %v", got)
+	}
+	results, err = table.List(ctx, 2, 1, []godmin.Order{{FieldName: "tenant"}, {FieldName: "Seats", Ascending: true}})
+	if got := names(t, results, err); !reflect.DeepEqual(got, []string{"Acme"}) {
+		t.Errorf("second page got %v", got)
+	}
+	if _, err := table.List(ctx, 2, 0, []godmin.Order{{FieldName: "seats; DROP TABLE customers"}}); !errors.Is(err, godmin.ErrBadRequest) {
+		t.Errorf("unknown order got %v", err)
+	}
+
+	conditions := []godmin.Condition{
+		{Field: "Signed", Op: godmin.OpGreaterOrEqual, Value: signed.AddDate(0, 0, 1)},
+		{Field: "email", Op: godmin.OpIsNull},
+	}
+	results, err = table.ListFiltered(ctx, 10, 0, nil, conditions)
+	if got := names(t, results, err); !reflect.DeepEqual(got, []string{"Globex"}) {
+		t.Errorf("filtered got %v", got)
+	}
+	if count, err := table.CountFiltered(ctx, conditions[:1]); err != nil || count != 2 {
+		t.Errorf("filtered count got %v, %v", count, err)
+	}
+
+	search := table.Searcher("Name", "Email")
+	results, total, err := search.SearchContext(ctx, 10, 0, "init", nil)
+	if got := names(t, results, err); total != 1 || !reflect.DeepEqual(got, []string{"Initech"}) {
+		t.Errorf("search got %v of %v", got, total)
+	}
+	for _, wildcard := range []string{"%", "_", "!"} {
+		if results, total, err := search.SearchContext(ctx, 10, 0, wildcard, nil); total != 0 {
+			t.Errorf("search for %q got %v of %v", wildcard, names(t, results, err), total)
+		}
+	}
+
+	if _, err := table.Upsert(ctx, "2", url.Values{"Seats": {"7"}, "Email": {"it@globex.test"}}); err != nil {
+		t.Fatal(err)
+	}
+	obj, _ = table.Get(ctx, "2")
+	if c := obj.(Customer); c.Seats != 7 || c.Email != "it@globex.test" || c.Name != "Globex" {
+		t.Errorf("upserted %+v", c)
+	}
+	if pk, err := table.Upsert(ctx, "", url.Values{"Name": {"Hooli"}, "Tenant": {"b"}}); err != nil || pk != "4" {
+		t.Errorf("inserted %v, %v", pk, err)
+	}
+
+	if err := table.DeletePK(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.Get(ctx, "1"); !errors.Is(err, godmin.ErrNotFound) {
+		t.Errorf("deleted object got %v", err)
+	}
+	if err := table.DeletePK(ctx, "1"); !errors.Is(err, godmin.ErrNotFound) {
+		t.Errorf("deleting a deleted object got %v", err)
+	}
+	if _, err := table.Save(ctx, "1", &Customer{Name: "Acme", Tenant: "a"}); !errors.Is(err, godmin.ErrNotFound) {
+		t.Errorf("saving a deleted object got %v", err)
+	}
+	if _, err := table.Get(ctx, "1"); !errors.Is(err, godmin.ErrNotFound) {
+		t.Errorf("saving a deleted object recreated it: %v", err)
+	}
+}
+
+func TestSearcherFields(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("unknown search field accepted")
+		}
+	}()
+	testTable(t).Searcher("Name", "Password")
+}
+
+type tenantAuthenticator struct{}
+
+func (tenantAuthenticator) IsAdmin(c *gin.Context) bool {
+	c.Set("username", "a")
+	return true
+}
+func (tenantAuthenticator) HasPrivilege(c *gin.Context, collection string, action string, ids []string) bool {
+	return true
+}
+
+type intPK struct{}
+
+func (intPK) PKString(pk interface{}) string { return fmt.Sprint(pk) }
+
+func TestAdmin(t *testing.T) {
+	table := testTable(t)
+	ctx := context.Background()
+	for _, c := range []Customer{{Name: "Acme", Seats: 50, Tenant: "a"}, {Name: "Globex", Seats: 5, Tenant: "a"}, {Name: "Initech", Seats: 500, Tenant: "b"}} {
+		if _, err := table.Save(ctx, "", &c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := godmin.NewAdmin()
+	admin.SetAuthenticator(tenantAuthenticator{})
+	ma := godmin.NewModelAdmin("customers", table.PKField(), map[string]bool{"Name": true, "Seats": true}, nil, nil, nil, nil, intPK{}, nil, table.Searcher("Name"))
+	ma.ContextAccessor = table
+	ma.FieldColumns = table.Columns()
+	ma.ListFilters = []godmin.ListFilter{{Field: "Seats", Kind: godmin.FilterNumberRange}}
+	ma.ScopeFunc = func(c *gin.Context) godmin.Scope { return godmin.Scope{"Tenant": c.GetString("username")} }
+	admin.Register(ma)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	tmpl := template.Must(template.New("admin/list.html").Parse("{{range .pks}}{{.}} {{end}}"))
+	template.Must(tmpl.New("admin/error.html").Parse("{{.error}}"))
+	r.SetHTMLTemplate(tmpl)
+	admin.Routes(r.Group("/admin"))
+
+	for query, want := range map[string]string{
+		"":                    "1 2 ",
+		"?o=-Seats":           "1 2 ",
+		"?o=Seats":            "2 1 ",
+		"?f.Seats.gte=10":     "1 ",
+		"?q=init":             "",
+		"?q=glo&o=-Name":      "2 ",
+		"?q=e&f.Seats.lte=10": "2 ",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/customers/"+query, nil))
+		if got := w.Body.String(); got != want {
+			t.Errorf("%q: got %q, want %q", query, got, want)
+		}
+	}
+	// other tenants' objects can't be deleted
+	form := url.Values{"action": {"delete"}, "csrf_token": {"t"}}
+	req := httptest.NewRequest("POST", "/admin/customers/3", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "godmin_csrf", Value: "t"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("deleting another tenant's object got status %d", w.Code)
+	}
+	if _, err := table.Get(context.Background(), "3"); err != nil {
+		t.Errorf("another tenant's object was deleted: %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+// Package sqlparam numbers the query parameters of SQL statements for the different databases,
+// for the SQL stores' Placeholder fields.
+package sqlparam
+
+import (
+	"fmt"
+)
+
+// Question numbers query parameters "?", as used by MySQL and SQLite.
+func Question(n int) string { return "?" }
+
+// Dollar numbers query parameters "$1", "$2" and so on, as used by PostgreSQL.
+func Dollar(n int) string { return fmt.Sprintf("$%d", n) }
@@ -0,0 +1,252 @@
+package godmin
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Saver is optionally implemented by Accessors that want the object decoded from the
+// change form rather than the raw form values passed to Upsert.
+// obj is a pointer to a value of the Prototype's type.
+type Saver interface {
+	Save(pk string, obj interface{}) (outPk string, err error)
+}
+
+// ContextSaver is the context-aware version of Saver, for ContextAccessors.
+type ContextSaver interface {
+	Save(ctx context.Context, pk string, obj interface{}) (outPk string, err error)
+}
+
+type legacySaver struct {
+	Saver
+}
+
+func (l legacySaver) Save(ctx context.Context, pk string, obj interface{}) (outPk string, err error) {
+	return l.Saver.Save(pk, obj)
+}
+
+// the ModelAdmin's accessor as a ContextSaver, or nil if it doesn't implement Save
+func (m *ModelAdmin) saver() ContextSaver {
+	accessor := m.accessor()
+	if optional, ok := accessor.(optionalAccessor); ok {
+		return optional.saver()
+	}
+	saver, _ := accessor.(ContextSaver)
+	return saver
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Decode reconstructs a value of base's type from form values keyed by the identifiers
+// Marshal produces, e.g. "Name", "Sub.Name" or "Subs.0.Name". Decoding starts from a copy
+// of base, so fields missing from the form keep their current values; base itself is not
+// modified. Struct, slice and map fields can also be given as a single JSON value, as
+// rendered by ValuesMapper. The result is a pointer to the decoded value, and any conversion
+// problems are reported per field in a *ValidationError. Keys that don't name a field are ignored.
+func Decode(values map[string][]string, base interface{}) (obj interface{}, err error) {
+	baseValue := reflect.ValueOf(base)
+	if baseValue.Kind() == reflect.Ptr {
+		baseValue = baseValue.Elem()
+	}
+	if baseValue.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("godmin: can't decode into %T", base)
+	}
+	out := reflect.New(baseValue.Type())
+	out.Elem().Set(baseValue)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	// slice elements are set in index order, as each can only extend its slice by one
+	sort.Slice(keys, func(i, j int) bool { return lessPath(keys[i], keys[j]) })
+	fieldErrors := make(map[string]string)
+	for _, key := range keys {
+		if err := setPath(out.Elem(), strings.Split(key, "."), values[key]); err != nil {
+			fieldErrors[key] = err.Error()
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return out.Interface(), &ValidationError{Fields: fieldErrors}
+	}
+	return out.Interface(), nil
+}
+
+// order form keys by their path segments, comparing indexes numerically
+func lessPath(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for k := 0; k < len(as) && k < len(bs); k++ {
+		if as[k] == bs[k] {
+			continue
+		}
+		ai, aErr := strconv.Atoi(as[k])
+		bi, bErr := strconv.Atoi(bs[k])
+		if aErr == nil && bErr == nil {
+			return ai < bi
+		}
+		return as[k] < bs[k]
+	}
+	return len(as) < len(bs)
+}
+
+// set the value at path below v, copying any pointers, slices and maps on the way
+// so that values shared with the original object aren't modified
+func setPath(v reflect.Value, path []string, vals []string) error {
+	if len(path) == 0 {
+		return setValue(v, vals)
+	}
+	if v.Kind() == reflect.Ptr {
+		copied := reflect.New(v.Type().Elem())
+		if !v.IsNil() {
+			copied.Elem().Set(v.Elem())
+		}
+		v.Set(copied)
+		v = copied.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		field, ok := v.Type().FieldByName(path[0])
+		if !ok || field.PkgPath != "" || len(field.Index) != 1 {
+			return nil
+		}
+		return setPath(v.Field(field.Index[0]), path[1:], vals)
+	case reflect.Slice:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i > v.Len() {
+			return fmt.Errorf("Invalid index %q.", path[0])
+		}
+		length := v.Len()
+		if i == length {
+			length++
+		}
+		copied := reflect.MakeSlice(v.Type(), length, length)
+		reflect.Copy(copied, v)
+		v.Set(copied)
+		return setPath(v.Index(i), path[1:], vals)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %v", v.Type().Key())
+		}
+		copied := reflect.MakeMap(v.Type())
+		for _, k := range v.MapKeys() {
+			copied.SetMapIndex(k, v.MapIndex(k))
+		}
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := copied.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := setPath(elem, path[1:], vals); err != nil {
+			return err
+		}
+		copied.SetMapIndex(key, elem)
+		v.Set(copied)
+		return nil
+	}
+	return fmt.Errorf("can't set %v on a %v", strings.Join(path, "."), v.Type())
+}
+
+// set v from its form values
+func setValue(v reflect.Value, vals []string) error {
+	s := ""
+	if len(vals) > 0 {
+		s = vals[0]
+	}
+	if v.Kind() == reflect.Ptr {
+		if strings.TrimSpace(s) == "" && len(vals) <= 1 {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), vals); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		if strings.TrimSpace(s) == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "", "false", "off", "0":
+			v.SetBool(false)
+		case "true", "on", "1":
+			v.SetBool(true)
+		default:
+			return errors.New("Must be true or false.")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if strings.TrimSpace(s) == "" {
+			v.SetInt(0)
+			return nil
+		}
+		i, err := strconv.ParseInt(strings.TrimSpace(s), 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("Must be a whole number.")
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if strings.TrimSpace(s) == "" {
+			v.SetUint(0)
+			return nil
+		}
+		u, err := strconv.ParseUint(strings.TrimSpace(s), 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("Must be a positive whole number.")
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if strings.TrimSpace(s) == "" {
+			v.SetFloat(0)
+			return nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), v.Type().Bits())
+		if err != nil {
+			return errors.New("Must be a number.")
+		}
+		v.SetFloat(f)
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+		trimmed := strings.TrimSpace(s)
+		if len(vals) <= 1 && trimmed == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if len(vals) <= 1 && (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") || trimmed == "null") {
+			target := reflect.New(v.Type())
+			if err := json.Unmarshal([]byte(trimmed), target.Interface()); err != nil {
+				return fmt.Errorf("Invalid JSON: %v.", err)
+			}
+			v.Set(target.Elem())
+			return nil
+		}
+		if v.Kind() != reflect.Slice {
+			return errors.New("Must be JSON.")
+		}
+		// a multi-valued form field, e.g. from checkboxes or a multiple select
+		slice := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i := range vals {
+			if err := setValue(slice.Index(i), vals[i:i+1]); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
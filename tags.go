@@ -0,0 +1,146 @@
+package godmin
+
+import (
+	"reflect"
+	"strings"
+)
+
+// tagName is the struct tag read for admin configuration, e.g.
+//
+//	Name  string `godmin:"list,sortable,label=Full Name,note='Shown to customers, keep it short'"`
+//	Bio   string `godmin:"widget=textarea"`
+//	Hash  string `godmin:"-"`
+//	Born  time.Time `godmin:"list,sortable,column=born_at"`
+//
+// Options are comma separated; values containing commas can be single-quoted.
+const tagName = "godmin"
+
+// tagOptions holds the options of a single field's godmin tag, flags have an empty value
+type tagOptions map[string]string
+
+func (t tagOptions) has(option string) bool {
+	_, ok := t[option]
+	return ok
+}
+
+// parse a godmin struct tag into its options
+func parseTag(tag string) tagOptions {
+	options := make(tagOptions)
+	var parts []string
+	inQuote, start := false, 0
+	for i, r := range tag {
+		switch {
+		case r == '\'':
+			inQuote = !inQuote
+		case r == ',' && !inQuote:
+			parts = append(parts, tag[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, tag[start:])
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value := part, ""
+		if eq := strings.IndexByte(part, '='); eq >= 0 {
+			name, value = strings.TrimSpace(part[:eq]), strings.TrimSpace(part[eq+1:])
+			if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+				value = value[1 : len(value)-1]
+			}
+		}
+		options[name] = value
+	}
+	return options
+}
+
+// fieldTags returns the parsed godmin tags of the prototype's fields, keyed by field name
+func fieldTags(proto interface{}) map[string]tagOptions {
+	tags := make(map[string]tagOptions)
+	protoType := reflect.TypeOf(proto)
+	if protoType == nil {
+		return tags
+	}
+	if protoType.Kind() == reflect.Ptr {
+		protoType = protoType.Elem()
+	}
+	if protoType.Kind() != reflect.Struct {
+		return tags
+	}
+	for i := 0; i < protoType.NumField(); i++ {
+		field := protoType.Field(i)
+		if tag, ok := field.Tag.Lookup(tagName); ok {
+			tags[field.Name] = parseTag(tag)
+		}
+	}
+	return tags
+}
+
+func copyBoolMap(in map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+// presentFields copies a field set, marking every field present in it true
+func presentFields(in map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(in))
+	for k := range in {
+		out[k] = true
+	}
+	return out
+}
+
+func copyStringMap(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+// applyTags fills in the ModelAdmin's field configuration from the Prototype's struct tags.
+// Entries already present in the ModelAdmin's maps take precedence over the tags,
+// and a non-nil ListFields replaces the tag-derived list fields entirely.
+// Any field present in OmitFields or ReadOnlyFields is omitted or read-only, whatever its value.
+func applyTags(ma *ModelAdmin) {
+	tags := fieldTags(ma.accessor().Prototype())
+	listFromTags := ma.ListFields == nil
+	ma.ListFields = copyBoolMap(ma.ListFields)
+	ma.OmitFields = presentFields(ma.OmitFields)
+	ma.ReadOnlyFields = presentFields(ma.ReadOnlyFields)
+	ma.FieldNotes = copyStringMap(ma.FieldNotes)
+	ma.FieldLabels = copyStringMap(ma.FieldLabels)
+	ma.FieldColumns = copyStringMap(ma.FieldColumns)
+	widgets := defaultWidgets(*ma)
+	for field, widget := range ma.FieldWidgets {
+		widgets[field] = widget
+	}
+	for field, tag := range tags {
+		if listFromTags && (tag.has("list") || tag.has("sortable")) {
+			ma.ListFields[field] = tag.has("sortable")
+		}
+		if _, exists := ma.OmitFields[field]; !exists && tag.has("-") {
+			ma.OmitFields[field] = true
+		}
+		if _, exists := ma.ReadOnlyFields[field]; !exists && tag.has("readonly") {
+			ma.ReadOnlyFields[field] = true
+		}
+		if _, exists := ma.FieldNotes[field]; !exists && tag["note"] != "" {
+			ma.FieldNotes[field] = tag["note"]
+		}
+		if _, exists := ma.FieldLabels[field]; !exists && tag["label"] != "" {
+			ma.FieldLabels[field] = tag["label"]
+		}
+		if _, exists := ma.FieldColumns[field]; !exists && tag["column"] != "" {
+			ma.FieldColumns[field] = tag["column"]
+		}
+		if _, exists := ma.FieldWidgets[field]; !exists && tag["widget"] != "" {
+			widgets[field] = tag["widget"]
+		}
+	}
+	ma.FieldWidgets = widgets
+}
@@ -0,0 +1,137 @@
+package godmin
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// TypedAccessor is a ContextAccessor for the struct type T, so an accessor returning the wrong type
+// fails to compile rather than at request time. Adapt it with Typed, or use NewTypedModelAdmin.
+type TypedAccessor[T any] interface {
+	Get(ctx context.Context, pk string) (result T, err error)
+	List(ctx context.Context, count, page int, order []Order) (results []T, err error)
+	Count(ctx context.Context) (count int, err error)
+	Upsert(ctx context.Context, pk string, values map[string][]string) (outPk string, err error)
+	DeletePK(ctx context.Context, pk string) (err error)
+}
+
+// TypedFilterAccessor is the FilterAccessor optionally implemented by TypedAccessors.
+type TypedFilterAccessor[T any] interface {
+	ListFiltered(ctx context.Context, count, page int, order []Order, conditions []Condition) (results []T, err error)
+	CountFiltered(ctx context.Context, conditions []Condition) (count int, err error)
+}
+
+// TypedSaver is the ContextSaver optionally implemented by TypedAccessors.
+type TypedSaver[T any] interface {
+	Save(ctx context.Context, pk string, obj *T) (outPk string, err error)
+}
+
+// optionalAccessor is implemented by adapters whose optional interfaces depend on the accessor they wrap
+type optionalAccessor interface {
+	filterAccessor() FilterAccessor
+	saver() ContextSaver
+	hooks() interface{} // the accessor implementing any object hooks
+}
+
+// typedAccessor adapts a TypedAccessor to the ContextAccessor interface
+type typedAccessor[T any] struct {
+	accessor TypedAccessor[T]
+}
+
+// Typed wraps a TypedAccessor so it can be used as a ModelAdmin's ContextAccessor,
+// along with its TypedFilterAccessor and TypedSaver methods if it has them.
+// It panics if T isn't a struct type, e.g. a pointer to one.
+func Typed[T any](accessor TypedAccessor[T]) ContextAccessor {
+	if typ := reflect.TypeOf((*T)(nil)).Elem(); typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("godmin: TypedAccessor of %v, which isn't a struct type", typ))
+	}
+	return typedAccessor[T]{accessor}
+}
+
+func (t typedAccessor[T]) Prototype() interface{} {
+	var zero T
+	return zero
+}
+
+func (t typedAccessor[T]) Get(ctx context.Context, pk string) (interface{}, error) {
+	result, err := t.accessor.Get(ctx, pk)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (t typedAccessor[T]) List(ctx context.Context, count, page int, order []Order) (interface{}, error) {
+	return t.accessor.List(ctx, count, page, order)
+}
+
+func (t typedAccessor[T]) Count(ctx context.Context) (int, error) {
+	return t.accessor.Count(ctx)
+}
+
+func (t typedAccessor[T]) Upsert(ctx context.Context, pk string, values map[string][]string) (string, error) {
+	return t.accessor.Upsert(ctx, pk, values)
+}
+
+func (t typedAccessor[T]) DeletePK(ctx context.Context, pk string) error {
+	return t.accessor.DeletePK(ctx, pk)
+}
+
+func (t typedAccessor[T]) filterAccessor() FilterAccessor {
+	if fa, ok := t.accessor.(TypedFilterAccessor[T]); ok {
+		return typedFilterAccessor[T]{fa}
+	}
+	return nil
+}
+
+func (t typedAccessor[T]) saver() ContextSaver {
+	if saver, ok := t.accessor.(TypedSaver[T]); ok {
+		return typedSaver[T]{saver}
+	}
+	return nil
+}
+
+func (t typedAccessor[T]) hooks() interface{} {
+	return t.accessor
+}
+
+type typedFilterAccessor[T any] struct {
+	accessor TypedFilterAccessor[T]
+}
+
+func (t typedFilterAccessor[T]) ListFiltered(ctx context.Context, count, page int, order []Order, conditions []Condition) (interface{}, error) {
+	return t.accessor.ListFiltered(ctx, count, page, order, conditions)
+}
+
+func (t typedFilterAccessor[T]) CountFiltered(ctx context.Context, conditions []Condition) (int, error) {
+	return t.accessor.CountFiltered(ctx, conditions)
+}
+
+type typedSaver[T any] struct {
+	saver TypedSaver[T]
+}
+
+func (t typedSaver[T]) Save(ctx context.Context, pk string, obj interface{}) (string, error) {
+	typed, ok := obj.(*T)
+	if !ok {
+		return "", fmt.Errorf("godmin: can't save a %T as a %T", obj, typed)
+	}
+	return t.saver.Save(ctx, pk, typed)
+}
+
+// TypedSearch adapts a search function returning a slice of T for use as a Searcher's SearchContext.
+func TypedSearch[T any](search func(ctx context.Context, count, page int, query string, order []Order) (results []T, totalCount int, err error)) func(ctx context.Context, count, page int, query string, order []Order) (interface{}, int, error) {
+	return func(ctx context.Context, count, page int, query string, order []Order) (interface{}, int, error) {
+		return search(ctx, count, page, query, order)
+	}
+}
+
+// NewTypedModelAdmin returns a ModelAdmin for the struct type T using the TypedAccessor,
+// which also provides the object hooks it implements. Like Typed, it panics if T isn't
+// a struct type. The field maps can be set on the result or through godmin struct tags on T.
+func NewTypedModelAdmin[T any](modelName string, pkFieldName string, pkStringer PKStringer, accessor TypedAccessor[T]) ModelAdmin {
+	ma := NewModelAdmin(modelName, pkFieldName, nil, nil, nil, nil, nil, pkStringer, nil, nil)
+	ma.ContextAccessor = Typed(accessor)
+	return ma
+}
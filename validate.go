@@ -0,0 +1,115 @@
+package godmin
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+// Validator is optionally implemented by models to check themselves before being saved.
+// Validate returns error messages keyed by field name, or an empty map if the object is valid.
+type Validator interface {
+	Validate() map[string]string
+}
+
+// Validate checks obj against the validation options in its fields' godmin tags:
+//
+//	required     the field can't be its zero value
+//	min=N,max=N  bounds for numbers, or for the length of strings, slices and maps
+//	regex=RE     strings must match the regular expression (quote it if it contains commas)
+//	email        strings must be an email address
+//
+// then calls obj's Validate method if it implements Validator.
+// Problems are returned as a *ValidationError, nil means obj is valid.
+func Validate(obj interface{}) error {
+	fieldErrors := make(map[string]string)
+	v := reflect.ValueOf(obj)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		for field, tag := range fieldTags(obj) {
+			if msg := validateField(v.FieldByName(field), tag); msg != "" {
+				fieldErrors[field] = msg
+			}
+		}
+	}
+	if validator, ok := obj.(Validator); ok {
+		for field, msg := range validator.Validate() {
+			fieldErrors[field] = msg
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return &ValidationError{Fields: fieldErrors}
+	}
+	return nil
+}
+
+// check a single field value against its tag options, returning a message if it's invalid
+func validateField(v reflect.Value, tag tagOptions) string {
+	if tag.has("required") && v.IsZero() {
+		return "This field is required."
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	var size float64
+	var measured, isLength bool
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size, measured = float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size, measured = float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		size, measured = v.Float(), true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		size, measured, isLength = float64(v.Len()), true, true
+	}
+	if measured {
+		for _, bound := range []string{"min", "max"} {
+			if !tag.has(bound) {
+				continue
+			}
+			limit, err := strconv.ParseFloat(tag[bound], 64)
+			if err != nil {
+				return fmt.Sprintf("Invalid %v in the field's tag.", bound)
+			}
+			if (bound == "min" && size >= limit) || (bound == "max" && size <= limit) {
+				continue
+			}
+			switch {
+			case isLength && bound == "min":
+				return fmt.Sprintf("Must have a length of at least %v.", tag[bound])
+			case isLength:
+				return fmt.Sprintf("Must have a length of at most %v.", tag[bound])
+			case bound == "min":
+				return fmt.Sprintf("Must be at least %v.", tag[bound])
+			default:
+				return fmt.Sprintf("Must be at most %v.", tag[bound])
+			}
+		}
+	}
+	if v.Kind() != reflect.String || v.Len() == 0 {
+		return ""
+	}
+	if tag.has("regex") {
+		re, err := regexp.Compile(tag["regex"])
+		if err != nil {
+			return "Invalid regex in the field's tag."
+		}
+		if !re.MatchString(v.String()) {
+			return "Invalid format."
+		}
+	}
+	if tag.has("email") {
+		if addr, err := mail.ParseAddress(v.String()); err != nil || addr.Address != v.String() {
+			return "Must be an email address."
+		}
+	}
+	return ""
+}
@@ -1,15 +1,16 @@
 package godmin
 
 import (
+	"encoding"
 	"encoding/json"
 	"fmt"
 	"reflect"
 )
 
-// Maps arbitrary values to string representations for display in HTML
+// Maps arbitrary values to string representations for display in HTML.
+// TextMarshalers such as time.Time are rendered with MarshalText, so Decode can parse them back.
 func ValuesMapper(item interface{}) (out map[string]string) {
-	fmt.Println("entering ValuesMapper")
-	itemValue := reflect.ValueOf(item)
+	itemValue := reflect.Indirect(reflect.ValueOf(item))
 	out = make(map[string]string)
 	if itemKind := itemValue.Kind(); itemKind != reflect.Struct {
 		return
@@ -23,14 +24,20 @@ func ValuesMapper(item interface{}) (out map[string]string) {
 		if val.IsValid() {
 			fieldInterface = val.Interface()
 			v, ok := fieldInterface.(fmt.Stringer)
-			if ok {
+			if tm, isText := fieldInterface.(encoding.TextMarshaler); isText {
+				if text, err := tm.MarshalText(); err == nil {
+					out[fieldName] = string(text)
+				} else {
+					out[fieldName] = fmt.Sprintf("%v", fieldInterface)
+				}
+			} else if ok {
 				out[fieldName] = v.String()
 			} else {
 				switch val.Kind() {
 				case reflect.Slice, reflect.Struct:
 					jv, err := json.MarshalIndent(val.Interface(), "", "  ")
 					if err != nil {
-						fmt.Println(err)
+						out[fieldName] = fmt.Sprintf("%v", fieldInterface)
 					} else {
 						out[fieldName] = fmt.Sprintf("%s", jv)
 					}
@@ -42,6 +49,5 @@ func ValuesMapper(item interface{}) (out map[string]string) {
 			out[fieldName] = ""
 		}
 	}
-	fmt.Println("leaving ValuesMapper")
 	return out
 }
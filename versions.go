@@ -0,0 +1,171 @@
+package godmin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version is a snapshot of an object's values, as rendered by ValuesMapper,
+// taken before it was changed or deleted through the admin.
+type Version struct {
+	Number   int // numbered from 1 for each object, set by the VersionStore
+	Time     time.Time
+	Username string
+	Model    string
+	PK       string
+	Values   map[string]string
+}
+
+// VersionStore keeps the previous versions of objects so admin users can compare and restore them.
+// The audit package provides an in-memory and an SQL VersionStore.
+type VersionStore interface {
+	// AddVersion stores the version, numbering it after the object's latest version.
+	AddVersion(ctx context.Context, version Version) (err error)
+	// Versions returns the versions of an object, latest first.
+	Versions(ctx context.Context, model string, pk string) (versions []Version, err error)
+}
+
+// store the values of an object about to be replaced, if there's a VersionStore,
+// logging rather than failing the request on errors
+func (a *Admin) addVersion(ctx context.Context, modelAdmin ModelAdmin, pk string, values map[string]string) {
+	if a.versions == nil {
+		return
+	}
+	version := Version{Time: time.Now(), Username: Username(ctx), Model: modelAdmin.ModelName, PK: pk, Values: values}
+	if version.Username == "" {
+		if accountId := AccountID(ctx); accountId != nil {
+			version.Username = fmt.Sprint(accountId)
+		}
+	}
+	if err := a.versions.AddVersion(ctx, version); err != nil {
+		a.logger.Printf("error storing a version of %v %v: %v", modelAdmin.ModelName, pk, err)
+	}
+}
+
+// find the numbered version, with 0 being the object's current values
+func findVersion(versions []Version, current map[string]string, number int) (values map[string]string, exists bool) {
+	if number == 0 {
+		return current, true
+	}
+	for _, version := range versions {
+		if version.Number == number {
+			return version.Values, true
+		}
+	}
+	return nil, false
+}
+
+// hide the values of fields the user can't see
+func visibleValues(modelAdmin ModelAdmin, values map[string]string) map[string]string {
+	visible := make(map[string]string, len(values))
+	for field, value := range values {
+		if !modelAdmin.OmitFields[field] {
+			visible[field] = value
+		}
+	}
+	return visible
+}
+
+// the stored versions of an object, comparing versions a and b (0 being the current values)
+// given in the query, by default the latest version and the current values
+func (a *Admin) versionList(c *gin.Context) {
+	modelAdmin, exists := a.lookupModelAdmin(c)
+	if !exists {
+		return
+	}
+	pk := c.Param("pk")
+	if !a.hasPermissions(c, modelAdmin.ModelName, PrivilegeView, []string{pk}) {
+		return
+	}
+	modelAdmin = a.restrictFields(c, modelAdmin)
+	ctx := modelAdmin.requestContext(c)
+	obj, err := modelAdmin.get(ctx, pk)
+	if err != nil {
+		a.renderError(c, err)
+		return
+	}
+	var versions []Version
+	if a.versions != nil {
+		if versions, err = a.versions.Versions(ctx, modelAdmin.ModelName, pk); err != nil {
+			a.renderError(c, err)
+			return
+		}
+	}
+	current := ValuesMapper(modelAdmin.load(ctx, obj))
+
+	dot := a.defaultDot(c)
+	dot["modelAdmin"] = modelAdmin
+	dot["pk"] = pk
+	dot["versions"] = versions
+	if len(versions) > 0 {
+		from, err := strconv.Atoi(c.DefaultQuery("a", strconv.Itoa(versions[0].Number)))
+		if err != nil {
+			a.renderError(c, badRequest(err))
+			return
+		}
+		to, err := strconv.Atoi(c.DefaultQuery("b", "0"))
+		if err != nil {
+			a.renderError(c, badRequest(err))
+			return
+		}
+		before, fromExists := findVersion(versions, current, from)
+		after, toExists := findVersion(versions, current, to)
+		if !fromExists || !toExists {
+			a.renderError(c, ErrNotFound)
+			return
+		}
+		dot["a"] = from
+		dot["b"] = to
+		dot["changes"] = diffValues(visibleValues(modelAdmin, before), visibleValues(modelAdmin, after))
+	}
+	c.HTML(http.StatusOK, "admin/versions.html", dot)
+}
+
+// restore an object to a previous version by saving its values as if submitted from the change form,
+// so they're validated and the object's hooks are called
+func (a *Admin) revert(c *gin.Context) {
+	modelAdmin, exists := a.lookupModelAdmin(c)
+	if !exists {
+		return
+	}
+	pk := c.Param("pk")
+	if !a.hasPermissions(c, modelAdmin.ModelName, PrivilegeChange, []string{pk}) {
+		return
+	}
+	if a.versions == nil {
+		a.renderError(c, ErrNotFound)
+		return
+	}
+	number, err := strconv.Atoi(c.PostForm("version"))
+	if err != nil {
+		a.renderError(c, badRequest(err))
+		return
+	}
+	ctx := modelAdmin.requestContext(c)
+	versions, err := a.versions.Versions(ctx, modelAdmin.ModelName, pk)
+	if err != nil {
+		a.renderError(c, err)
+		return
+	}
+	values, exists := findVersion(versions, nil, number)
+	if !exists || number == 0 {
+		a.renderError(c, fmt.Errorf("%w: no version %d", ErrNotFound, number))
+		return
+	}
+	form := make(url.Values, len(values))
+	for field, value := range values {
+		form.Set(field, value)
+	}
+	c.Request.Form = form
+	if !a.saveFromForm(c) {
+		return
+	}
+	a.AddFlash(c, FlashSuccess, fmt.Sprintf("The %v was restored to version %d.", modelAdmin.ModelName, number))
+	c.Redirect(http.StatusFound, fmt.Sprintf("%v/%v/%v", a.adminPath, c.Param("model"), url.PathEscape(pk)))
+}
@@ -5,21 +5,25 @@ import (
 )
 
 func defaultWidgets(ma ModelAdmin) (widgets map[string]string) {
-	proto := ma.Accessor.Prototype()
-	itemValue := reflect.ValueOf(proto)
 	widgets = make(map[string]string)
-	itemType := itemValue.Type()
-	for i := 0; i < itemValue.NumField(); i++ {
+	itemType := reflect.TypeOf(ma.accessor().Prototype())
+	if itemType == nil {
+		return
+	}
+	if itemType.Kind() == reflect.Ptr {
+		itemType = itemType.Elem()
+	}
+	if itemType.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < itemType.NumField(); i++ {
 		fieldName := itemType.Field(i).Name
-		fieldKind := itemValue.Field(i).Interface()
+		fieldType := itemType.Field(i).Type
 		widgets[fieldName] = "text"
-		switch fieldKind.(type) {
-		case *bool:
-			widgets[fieldName] = "radio"
-		case bool:
+		if fieldType.Kind() == reflect.Bool || (fieldType.Kind() == reflect.Ptr && fieldType.Elem().Kind() == reflect.Bool) {
 			widgets[fieldName] = "radio"
 		}
-		switch itemType.Field(i).Type.Kind() {
+		switch fieldType.Kind() {
 		case reflect.Struct, reflect.Slice:
 			widgets[fieldName] = "textarea"
 		}
@@ -40,11 +40,11 @@ func Marshal(in interface{}, admin ModelAdmin, idPrefix string) []AdminField {
 		if existsIn(name, admin.ListFields) {
 			af.List = true
 		}
-		if existsIn(name, admin.OmitFields) {
-			af.Omit = true
-		}
-		if existsIn(name, admin.ReadOnlyFields) {
-			af.ReadOnly = true
+		af.Omit = existsIn(name, admin.OmitFields)
+		af.ReadOnly = existsIn(name, admin.ReadOnlyFields)
+		if af.Omit {
+			out = append(out, af) // without its value
+			continue
 		}
 
 		var value string
@@ -114,14 +114,15 @@ func Marshal(in interface{}, admin ModelAdmin, idPrefix string) []AdminField {
 }
 
 // Unmarshal values with identfiers provided by Marshal into a map[string][]string
-// excluding Omit and ReadOnly fields
+// excluding Omit and ReadOnly fields, and any values nested within them
 func Unmarshal(values url.Values, modelAdmin *ModelAdmin) (out map[string][]string) {
 	out = make(map[string][]string)
 	for key, val := range values {
-		if _, skip := modelAdmin.ReadOnlyFields[key]; skip {
-			continue
+		field := key
+		if i := strings.Index(key, "."); i >= 0 {
+			field = key[:i]
 		}
-		if _, skip := modelAdmin.OmitFields[key]; skip {
+		if existsIn(field, modelAdmin.ReadOnlyFields) || existsIn(field, modelAdmin.OmitFields) {
 			continue
 		}
 		out[key] = val